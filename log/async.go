@@ -0,0 +1,239 @@
+package log
+
+import (
+  "sync"
+  "sync/atomic"
+  "time"
+)
+
+// stopFlushTimeout bounds how long Stop and FATAL logs wait for queued
+// records to be written.
+const stopFlushTimeout = 5 * time.Second
+
+const (
+  overflowBlock = iota
+  overflowDropNewest
+  overflowDropOldest
+  overflowDropBelow
+)
+
+// OverflowPolicy tells an asynchronous logger what to do with a record when
+// its queue is full.
+type OverflowPolicy struct {
+  mode  int
+  level LogLevel
+}
+
+var (
+  // Block waits until the queue has room.
+  Block = OverflowPolicy{mode: overflowBlock}
+  // DropNewest discards the record being logged.
+  DropNewest = OverflowPolicy{mode: overflowDropNewest}
+  // DropOldest discards the oldest queued record.
+  DropOldest = OverflowPolicy{mode: overflowDropOldest}
+)
+
+// DropBelow discards records below level and blocks for the others.
+func DropBelow(level LogLevel) OverflowPolicy {
+  return OverflowPolicy{mode: overflowDropBelow, level: level}
+}
+
+// Async returns a function to write records from a background goroutine
+// through a queue holding up to size records.
+func Async(size int, policy OverflowPolicy) func(Logger) Logger {
+  return func(l Logger) Logger {
+    l.asyncSize = size
+    l.overflow = policy
+    return l
+  }
+}
+
+// asyncQueue is a bounded ring buffer of records drained by a single writer
+// goroutine.
+type asyncQueue struct {
+  mu       sync.Mutex
+  notEmpty *sync.Cond
+  notFull  *sync.Cond
+  records  []*Record
+  head     int
+  n        int
+  busy     bool
+  closed   bool
+  flushers []chan struct{}
+  policy   OverflowPolicy
+  dropped  uint64
+  sinks    *atomic.Pointer[[]sinkEntry]
+  done     chan struct{}
+}
+
+func newAsyncQueue(size int, policy OverflowPolicy, sinks *atomic.Pointer[[]sinkEntry]) *asyncQueue {
+  if size < 1 {
+    size = 1
+  }
+  q := &asyncQueue{
+    records: make([]*Record, size),
+    policy:  policy,
+    sinks:   sinks,
+    done:    make(chan struct{}),
+  }
+  q.notEmpty = sync.NewCond(&q.mu)
+  q.notFull = sync.NewCond(&q.mu)
+  go q.run()
+  return q
+}
+
+// push queues a copy of r, applying the overflow policy when full.
+func (q *asyncQueue) push(r *Record) {
+  rec := new(Record)
+  *rec = *r
+  q.mu.Lock()
+  defer q.mu.Unlock()
+  for !q.closed && q.n == len(q.records) {
+    switch q.policy.mode {
+    case overflowDropNewest:
+      atomic.AddUint64(&q.dropped, 1)
+      return
+    case overflowDropOldest:
+      q.records[q.head] = nil
+      q.head = (q.head + 1) % len(q.records)
+      q.n--
+      atomic.AddUint64(&q.dropped, 1)
+      continue
+    case overflowDropBelow:
+      if rec.Level < q.policy.level {
+        atomic.AddUint64(&q.dropped, 1)
+        return
+      }
+    }
+    q.notFull.Wait()
+  }
+  if q.closed {
+    atomic.AddUint64(&q.dropped, 1)
+    return
+  }
+  q.records[(q.head+q.n)%len(q.records)] = rec
+  q.n++
+  q.notEmpty.Signal()
+}
+
+func (q *asyncQueue) run() {
+  defer close(q.done)
+  batch := make([]*Record, 0, len(q.records))
+  for {
+    q.mu.Lock()
+    for q.n == 0 && !q.closed {
+      q.notEmpty.Wait()
+    }
+    if q.n == 0 {
+      q.mu.Unlock()
+      return
+    }
+    for ; q.n > 0; q.n-- {
+      batch = append(batch, q.records[q.head])
+      q.records[q.head] = nil
+      q.head = (q.head + 1) % len(q.records)
+    }
+    q.busy = true
+    q.notFull.Broadcast()
+    q.mu.Unlock()
+
+    var sinks []sinkEntry
+    if p := q.sinks.Load(); p != nil {
+      sinks = *p
+    }
+    for i, r := range batch {
+      writeSinks(sinks, r)
+      batch[i] = nil
+    }
+    batch = batch[:0]
+
+    q.mu.Lock()
+    q.busy = false
+    if q.n == 0 {
+      for _, ch := range q.flushers {
+        close(ch)
+      }
+      q.flushers = nil
+    }
+    q.mu.Unlock()
+  }
+}
+
+// flush waits until every queued record is written or timeout elapses.
+func (q *asyncQueue) flush(timeout time.Duration) bool {
+  q.mu.Lock()
+  if q.n == 0 && !q.busy {
+    q.mu.Unlock()
+    return true
+  }
+  ch := make(chan struct{})
+  q.flushers = append(q.flushers, ch)
+  q.mu.Unlock()
+
+  timer := time.NewTimer(timeout)
+  defer timer.Stop()
+  select {
+  case <-ch:
+    return true
+  case <-timer.C:
+    return false
+  }
+}
+
+// close stops the writer once the queue is drained. Records logged after
+// close are dropped.
+func (q *asyncQueue) close(timeout time.Duration) {
+  q.flush(timeout)
+  q.mu.Lock()
+  q.closed = true
+  q.notEmpty.Broadcast()
+  q.notFull.Broadcast()
+  q.mu.Unlock()
+  select {
+  case <-q.done:
+  case <-time.After(timeout):
+  }
+}
+
+// flusher is implemented by sinks queueing records.
+type flusher interface {
+  Flush(timeout time.Duration) bool
+}
+
+// Flush waits until the records queued by an asynchronous logger, or by
+// its sinks, are written, and reports whether it happened within timeout.
+func (l *Logger) Flush(timeout time.Duration) bool {
+  ok := true
+  if l.state != nil && l.state.async != nil {
+    ok = l.state.async.flush(timeout)
+  }
+  for _, s := range l.loadSinks() {
+    if f, isFlusher := s.sink.(flusher); isFlusher && !f.Flush(timeout) {
+      ok = false
+    }
+  }
+  return ok
+}
+
+// Dropped returns the number of records discarded by the overflow policy.
+func (l *Logger) Dropped() uint64 {
+  if l.state == nil || l.state.async == nil {
+    return 0
+  }
+  return atomic.LoadUint64(&l.state.async.dropped)
+}
+
+// Flush waits until the queued records are written.
+func (l *LogAdaptor) Flush(timeout time.Duration) bool {
+  return l.current().Flush(timeout)
+}
+
+// Dropped returns the number of records discarded by the overflow policy.
+func (l *LogAdaptor) Dropped() uint64 {
+  return l.current().Dropped()
+}
+
+// Flush waits until the records queued by the default logger are written.
+func Flush(timeout time.Duration) bool {
+  return pkgAdaptor().Flush(timeout)
+}
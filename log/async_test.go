@@ -0,0 +1,79 @@
+package log
+
+import (
+  "sync"
+  "testing"
+  "time"
+)
+
+// gateSink blocks every write until the gate is opened.
+type gateSink struct {
+  gate chan struct{}
+  mu   sync.Mutex
+  msgs []string
+}
+
+func (s *gateSink) WriteRecord(r *Record) error {
+  <-s.gate
+  s.mu.Lock()
+  s.msgs = append(s.msgs, r.Message)
+  s.mu.Unlock()
+  return nil
+}
+
+func (s *gateSink) Close() error {
+  return nil
+}
+
+func TestAsyncFlush(t *testing.T) {
+  sink := &gateSink{gate: make(chan struct{})}
+  close(sink.gate)
+  inst := NewLogInstance(LogSink(sink, INFO), Async(16, Block))
+  l := NewAdaptorFromInstance(inst, 3)
+  for i := 0; i < 100; i++ {
+    l.Infof("msg %d", i)
+  }
+  if !inst.Flush(time.Second) {
+    t.Fatal("flush timed out")
+  }
+  inst.Stop()
+  if len(sink.msgs) != 100 || sink.msgs[99] != "msg 99" {
+    t.Errorf("got %d records", len(sink.msgs))
+  }
+}
+
+func TestAsyncDropPolicies(t *testing.T) {
+  for _, tc := range []struct {
+    policy OverflowPolicy
+    first  string
+  }{
+    {DropNewest, "msg 0"},
+    {DropOldest, "msg 8"},
+  } {
+    sink := &gateSink{gate: make(chan struct{})}
+    inst := NewLogInstance(LogSink(sink, INFO), Async(4, tc.policy))
+    l := NewAdaptorFromInstance(inst, 3)
+    l.Infof("blocked")
+    // wait for the writer to pick up the first record
+    for !queueBusy(inst.state.async) {
+      time.Sleep(time.Millisecond)
+    }
+    for i := 0; i < 12; i++ {
+      l.Infof("msg %d", i)
+    }
+    if n := inst.Dropped(); n != 8 {
+      t.Errorf("dropped %d records, want 8", n)
+    }
+    close(sink.gate)
+    inst.Stop()
+    if len(sink.msgs) != 5 || sink.msgs[1] != tc.first {
+      t.Errorf("got %q", sink.msgs)
+    }
+  }
+}
+
+func queueBusy(q *asyncQueue) bool {
+  q.mu.Lock()
+  defer q.mu.Unlock()
+  return q.busy
+}
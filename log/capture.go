@@ -0,0 +1,224 @@
+package log
+
+import (
+  "bytes"
+  "log"
+  "reflect"
+  "runtime"
+  "sort"
+  "strings"
+  "sync"
+)
+
+// DefaultCapturePrefixes maps the usual level prefixes of captured lines to
+// levels.
+var DefaultCapturePrefixes = map[string]LogLevel{
+  "[TRACE]":   TRACE,
+  "[DEBUG]":   DEBUG,
+  "[INFO]":    INFO,
+  "[WARN]":    WARN,
+  "[WARNING]": WARN,
+  "[ERROR]":   ERROR,
+  "[FATAL]":   FATAL,
+  "TRACE:":    TRACE,
+  "DEBUG:":    DEBUG,
+  "INFO:":     INFO,
+  "WARN:":     WARN,
+  "WARNING:":  WARN,
+  "ERROR:":    ERROR,
+  "FATAL:":    FATAL,
+}
+
+// CaptureConfig configures a CaptureWriter.
+type CaptureConfig struct {
+  // Level of the captured lines, unless they start with one of Prefixes,
+  // which is then removed from the message.
+  Level    LogLevel
+  Prefixes map[string]LogLevel
+  // Lines longer than MaxLineSize (default 64KB) are split.
+  MaxLineSize int
+  // SkipPackages lists the packages whose frames are skipped to find the
+  // caller, besides the standard log, fmt, io and bufio packages.
+  SkipPackages []string
+}
+
+type capturePrefix struct {
+  prefix string
+  level  LogLevel
+}
+
+// CaptureWriter is an io.Writer logging every line written to it. Partial
+// writes are put together until the line ends.
+type CaptureWriter struct {
+  adaptor  *LogAdaptor
+  fields   []Field
+  conf     CaptureConfig
+  prefixes []capturePrefix // longest first
+  skip     map[string]bool
+  mu       sync.Mutex
+  buf      []byte
+}
+
+// captureMethods is the name prefix of the CaptureWriter methods, whose
+// frames are skipped to find the caller.
+var captureMethods = funcPackage(runtime.FuncForPC(reflect.ValueOf(funcPackage).Pointer()).Name()) +
+  ".(*CaptureWriter)."
+
+// NewCaptureWriter returns a writer logging its lines through l, like the
+// other logging methods of l.
+func (l *LogAdaptor) NewCaptureWriter(conf CaptureConfig) *CaptureWriter {
+  if conf.MaxLineSize <= 0 {
+    conf.MaxLineSize = 64 << 10
+  }
+  w := &CaptureWriter{
+    adaptor: l,
+    fields:  l.fields,
+    conf:    conf,
+    skip:    map[string]bool{"log": true, "log/internal": true, "fmt": true, "io": true, "bufio": true},
+  }
+  for prefix, level := range conf.Prefixes {
+    w.prefixes = append(w.prefixes, capturePrefix{prefix: prefix, level: level})
+  }
+  sort.Slice(w.prefixes, func(i, j int) bool {
+    return len(w.prefixes[i].prefix) > len(w.prefixes[j].prefix)
+  })
+  for _, pkg := range conf.SkipPackages {
+    w.skip[pkg] = true
+  }
+  return w
+}
+
+// Write logs the complete lines of p and keeps the rest for the next
+// writes.
+func (w *CaptureWriter) Write(p []byte) (int, error) {
+  w.mu.Lock()
+  defer w.mu.Unlock()
+  n := len(p)
+  for len(p) > 0 {
+    i := bytes.IndexByte(p, '\n')
+    if i < 0 {
+      w.buf = append(w.buf, p...)
+      for len(w.buf) >= w.conf.MaxLineSize {
+        w.emit(string(w.buf[:w.conf.MaxLineSize]))
+        w.buf = append(w.buf[:0], w.buf[w.conf.MaxLineSize:]...)
+      }
+      break
+    }
+    line := p[:i]
+    if len(w.buf) > 0 {
+      line = append(w.buf, line...)
+      w.buf = w.buf[:0]
+    }
+    for len(line) > w.conf.MaxLineSize {
+      w.emit(string(line[:w.conf.MaxLineSize]))
+      line = line[w.conf.MaxLineSize:]
+    }
+    w.emit(strings.TrimSuffix(string(line), "\r"))
+    p = p[i+1:]
+  }
+  return n, nil
+}
+
+// Flush logs the pending partial line.
+func (w *CaptureWriter) Flush() {
+  w.mu.Lock()
+  defer w.mu.Unlock()
+  if len(w.buf) > 0 {
+    w.emit(string(w.buf))
+    w.buf = w.buf[:0]
+  }
+}
+
+// Close flushes the pending partial line.
+func (w *CaptureWriter) Close() error {
+  w.Flush()
+  return nil
+}
+
+func (w *CaptureWriter) emit(msg string) {
+  level := w.conf.Level
+  for _, p := range w.prefixes {
+    if strings.HasPrefix(msg, p.prefix) {
+      level = p.level
+      msg = strings.TrimLeft(msg[len(p.prefix):], " \t")
+      break
+    }
+  }
+  l := w.adaptor.current()
+  if !l.running() {
+    return
+  }
+  frame := w.caller()
+  if !l.enabledFrame(frame, level) {
+    return
+  }
+  if l.state.sampler != nil && !l.state.sampler.allowAt(frame.PC, frame.File, frame.Line, level, msg) {
+    return
+  }
+  r := Record{
+    Time:    l.now(),
+    Level:   level,
+    Message: msg,
+    Fields:  w.fields,
+  }
+  if l.callerFlags() > 0 {
+    l.setCaller(&r, frame.Function, frame.File, frame.Line)
+  }
+  l.dispatch(&r)
+  if level == FATAL {
+    // the writer, not us, decides whether to exit
+    l.flushAll()
+  }
+}
+
+// caller returns the first frame outside of the writer and the skipped
+// packages.
+func (w *CaptureWriter) caller() runtime.Frame {
+  var pcs [32]uintptr
+  frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs[:])])
+  for {
+    frame, more := frames.Next()
+    if !strings.HasPrefix(frame.Function, captureMethods) && !w.skip[funcPackage(frame.Function)] {
+      return frame
+    }
+    if !more {
+      return runtime.Frame{Function: "???", File: "???"}
+    }
+  }
+}
+
+// funcPackage returns the package path of a function name.
+func funcPackage(name string) string {
+  slash := strings.LastIndex(name, "/")
+  if dot := strings.Index(name[slash+1:], "."); dot >= 0 {
+    return name[:slash+1+dot]
+  }
+  return name
+}
+
+// CaptureStdLog redirects the output of the standard log package to l,
+// without its timestamp and prefix. The returned function restores the
+// previous output. The logger itself must not write to the standard logger.
+func (l *LogAdaptor) CaptureStdLog(conf CaptureConfig) (restore func()) {
+  w := l.NewCaptureWriter(conf)
+  out, flags, prefix := log.Writer(), log.Flags(), log.Prefix()
+  log.SetOutput(w)
+  log.SetFlags(0)
+  log.SetPrefix("")
+  return func() {
+    log.SetOutput(out)
+    log.SetFlags(flags)
+    log.SetPrefix(prefix)
+    w.Flush()
+  }
+}
+
+// CaptureStdLog redirects the output of the standard log package to the
+// default logger.
+func CaptureStdLog(conf CaptureConfig) (restore func()) {
+  d := std.Load()
+  if d == nil {
+    return func() {}
+  }
+  return d.adaptor.CaptureStdLog(conf)
+}
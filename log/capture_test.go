@@ -0,0 +1,69 @@
+package log
+
+import (
+  "fmt"
+  "log"
+  "os"
+  "path"
+  "strings"
+  "testing"
+  "time"
+)
+
+func TestCaptureStdLog(t *testing.T) {
+  dir := t.TempDir()
+  inst := NewLogInstance(LogFilePath(dir, "capture.log"), LogFlags(Lfile|Lline))
+  l := NewAdaptorFromInstance(inst, 3)
+
+  restore := l.With("src", "std").CaptureStdLog(CaptureConfig{Level: INFO, Prefixes: DefaultCapturePrefixes})
+  log.Printf("[ERROR] boom %d", 1)
+  log.Print("plain")
+  restore()
+
+  w := l.NewCaptureWriter(CaptureConfig{Level: WARN})
+  fmt.Fprint(w, "par")
+  fmt.Fprint(w, "tial\r\nnext")
+  w.Close()
+  l.Stop()
+
+  data, err := os.ReadFile(path.Join(dir, "capture.log"))
+  if err != nil {
+    t.Fatal(err)
+  }
+  lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+  if len(lines) != 4 {
+    t.Fatalf("got %d lines: %q", len(lines), data)
+  }
+  want := []string{
+    "ERR [log.TestCaptureStdLog] (capture_test.go:19): boom 1 src=std",
+    "INF [log.TestCaptureStdLog] (capture_test.go:20): plain src=std",
+    "WRN [log.TestCaptureStdLog] (capture_test.go:25): partial",
+    "WRN [log.TestCaptureStdLog] (capture_test.go:26): next",
+  }
+  for i, line := range lines {
+    if !strings.HasSuffix(line, want[i]) {
+      t.Errorf("got %q, want suffix %q", line, want[i])
+    }
+  }
+}
+
+func TestCaptureRulesAndSampling(t *testing.T) {
+  // the writer follows the default logger set afterwards
+  w := Default().NewCaptureWriter(CaptureConfig{Level: WARN, Prefixes: DefaultCapturePrefixes})
+  dir := t.TempDir()
+  a := New(LogFilePath(dir, "capture.log"), LevelRules("file:capture_test.go=ERROR"), Sample(1, 0, time.Hour))
+  SetDefault(a)
+  fmt.Fprintln(w, "below the file rule")
+  for i := 0; i < 3; i++ {
+    fmt.Fprintln(w, "[ERROR] repeated")
+  }
+  Stop()
+
+  data, _ := os.ReadFile(path.Join(dir, "capture.log"))
+  if strings.Contains(string(data), "below the file rule") {
+    t.Errorf("file rule ignored: %q", data)
+  }
+  if n := strings.Count(string(data), ": repeated\n"); n != 1 {
+    t.Errorf("got %d sampled lines: %q", n, data)
+  }
+}
@@ -0,0 +1,84 @@
+package log
+
+import (
+  "compress/gzip"
+  "fmt"
+  "io"
+  "os"
+)
+
+// Compressor compresses rotated log files. Other formats such as zstd can
+// be plugged in by implementing it.
+type Compressor interface {
+  // Ext returns the extension appended to compressed files, e.g. ".gz".
+  Ext() string
+  // Compress writes the compressed content of src to dst.
+  Compress(dst io.Writer, src io.Reader) error
+}
+
+// GzipCompressor compresses rotated log files with gzip.
+var GzipCompressor Compressor = gzipCompressor{}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) Ext() string {
+  return ".gz"
+}
+
+func (gzipCompressor) Compress(dst io.Writer, src io.Reader) error {
+  zw := gzip.NewWriter(dst)
+  if _, err := io.Copy(zw, src); err != nil {
+    zw.Close()
+    return err
+  }
+  return zw.Close()
+}
+
+// Compress returns a function to compress rotated log files with c in the
+// background.
+func Compress(c Compressor) func(Logger) Logger {
+  return func(l Logger) Logger {
+    l.compressor = c
+    return l
+  }
+}
+
+// compressFile compresses name to name+c.Ext() through a temporary file,
+// so that a partially compressed file never carries the final name, and
+// removes name on success. On failure name is left intact.
+func compressFile(c Compressor, name string) error {
+  src, err := os.Open(name)
+  if err != nil {
+    return err
+  }
+  defer src.Close()
+
+  target := name + c.Ext()
+  tmp := target + ".tmp"
+  dst, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+  if err != nil {
+    return err
+  }
+  err = c.Compress(dst, src)
+  if err == nil {
+    err = dst.Sync()
+  }
+  if cerr := dst.Close(); err == nil {
+    err = cerr
+  }
+  if err == nil {
+    err = os.Rename(tmp, target)
+  }
+  if err != nil {
+    os.Remove(tmp)
+    return err
+  }
+  src.Close()
+  return os.Remove(name)
+}
+
+func (ls *logSegment) compress(name string) {
+  if err := compressFile(ls.compressor, name); err != nil {
+    fmt.Fprintf(os.Stderr, "log: can't compress %s: %v\n", name, err)
+  }
+}
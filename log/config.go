@@ -0,0 +1,504 @@
+package log
+
+import (
+  "encoding"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "os"
+  "reflect"
+  "strconv"
+  "strings"
+  "time"
+)
+
+// Config describes a logger built by NewFromConfig. It can be unmarshalled
+// from JSON or YAML, and overridden by environment variables with LoadEnv.
+// Durations are strings such as "90s" or "24h", sizes are numbers of bytes
+// with an optional KB, MB or GB suffix.
+type Config struct {
+  Level      LogLevel `json:"level" yaml:"level" env:"LEVEL"`
+  LevelRules string   `json:"level_rules,omitempty" yaml:"level_rules,omitempty" env:"LEVEL_RULES"`
+  // Encoder is "text" (default), "json" or "logfmt".
+  Encoder string `json:"encoder,omitempty" yaml:"encoder,omitempty" env:"ENCODER"`
+  // Caller is "none" (default), "func", "file" or "line".
+  Caller string `json:"caller,omitempty" yaml:"caller,omitempty" env:"CALLER"`
+  // TimeLayout is "rfc3339", "rfc3339nano", "epochmillis" or a time
+  // layout, see LogTimeLayout. UTC writes times in UTC.
+  TimeLayout string `json:"time_layout,omitempty" yaml:"time_layout,omitempty" env:"TIME_LAYOUT"`
+  UTC        bool   `json:"utc,omitempty" yaml:"utc,omitempty" env:"UTC"`
+  // Stdout also writes the lines to the standard logger.
+  Stdout bool `json:"stdout,omitempty" yaml:"stdout,omitempty" env:"STDOUT"`
+  // File is the main output, stderr without it.
+  File     *FileConfig     `json:"file,omitempty" yaml:"file,omitempty" env:"FILE"`
+  Async    *AsyncConfig    `json:"async,omitempty" yaml:"async,omitempty" env:"ASYNC"`
+  Sampling *SamplingConfig `json:"sampling,omitempty" yaml:"sampling,omitempty" env:"SAMPLING"`
+  // Sinks are additional outputs. From the environment they are read as
+  // a JSON array.
+  Sinks []SinkConfig `json:"sinks,omitempty" yaml:"sinks,omitempty" env:"SINKS"`
+}
+
+// FileConfig describes a rotated log file.
+type FileConfig struct {
+  Path string `json:"path" yaml:"path" env:"PATH"`
+  Name string `json:"name,omitempty" yaml:"name,omitempty" env:"NAME"`
+  // Rotate is "minute", "hour", "day" or a duration, no time based
+  // rotation when empty.
+  Rotate       string `json:"rotate,omitempty" yaml:"rotate,omitempty" env:"ROTATE"`
+  MaxSize      string `json:"max_size,omitempty" yaml:"max_size,omitempty" env:"MAX_SIZE"`
+  MaxBackups   int    `json:"max_backups,omitempty" yaml:"max_backups,omitempty" env:"MAX_BACKUPS"`
+  MaxAge       string `json:"max_age,omitempty" yaml:"max_age,omitempty" env:"MAX_AGE"`
+  MaxTotalSize string `json:"max_total_size,omitempty" yaml:"max_total_size,omitempty" env:"MAX_TOTAL_SIZE"`
+  Compress     bool   `json:"compress,omitempty" yaml:"compress,omitempty" env:"COMPRESS"`
+}
+
+// AsyncConfig describes the queue of an asynchronous logger.
+type AsyncConfig struct {
+  Size int `json:"size" yaml:"size" env:"SIZE"`
+  // Overflow is "block" (default), "drop_newest", "drop_oldest" or
+  // "drop_below:LEVEL".
+  Overflow string `json:"overflow,omitempty" yaml:"overflow,omitempty" env:"OVERFLOW"`
+}
+
+// SamplingConfig describes the sampling of repeated records, see Sample.
+type SamplingConfig struct {
+  First      int    `json:"first" yaml:"first" env:"FIRST"`
+  Thereafter int    `json:"thereafter" yaml:"thereafter" env:"THEREAFTER"`
+  Interval   string `json:"interval" yaml:"interval" env:"INTERVAL"`
+  // Bypass, when set, is the level from which records are never sampled.
+  Bypass *LogLevel `json:"bypass,omitempty" yaml:"bypass,omitempty" env:"BYPASS"`
+}
+
+// SinkConfig describes an additional output receiving the records at or
+// above Level.
+type SinkConfig struct {
+  // Type is "stderr", "stdout", "file", "syslog" or "shipper".
+  Type    string   `json:"type" yaml:"type"`
+  Level   LogLevel `json:"level,omitempty" yaml:"level,omitempty"`
+  Encoder string   `json:"encoder,omitempty" yaml:"encoder,omitempty"`
+  // File configures a "file" sink.
+  File *FileConfig `json:"file,omitempty" yaml:"file,omitempty"`
+  // Network, Addr, Format ("rfc5424" or "rfc3164"), Facility ("user",
+  // "daemon", "auth", "local0" to "local7") and AppName configure a
+  // "syslog" sink.
+  Network  string `json:"network,omitempty" yaml:"network,omitempty"`
+  Addr     string `json:"addr,omitempty" yaml:"addr,omitempty"`
+  Format   string `json:"format,omitempty" yaml:"format,omitempty"`
+  Facility string `json:"facility,omitempty" yaml:"facility,omitempty"`
+  AppName  string `json:"app_name,omitempty" yaml:"app_name,omitempty"`
+  // URL, BatchSize, FlushInterval and SpillDir configure a "shipper"
+  // sink.
+  URL           string `json:"url,omitempty" yaml:"url,omitempty"`
+  BatchSize     int    `json:"batch_size,omitempty" yaml:"batch_size,omitempty"`
+  FlushInterval string `json:"flush_interval,omitempty" yaml:"flush_interval,omitempty"`
+  SpillDir      string `json:"spill_dir,omitempty" yaml:"spill_dir,omitempty"`
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (lv LogLevel) MarshalText() ([]byte, error) {
+  if _, ok := levelName[lv]; !ok {
+    return nil, fmt.Errorf("log: unknown level %d", int(lv))
+  }
+  return []byte(lv.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (lv *LogLevel) UnmarshalText(text []byte) error {
+  level, err := ParseLevel(string(text))
+  if err != nil {
+    return err
+  }
+  *lv = level
+  return nil
+}
+
+// LoadEnv overrides c with the environment variables named after the env
+// tags of the fields and prefixed with prefix and an underscore, e.g.
+// APP_LEVEL, APP_FILE_PATH or APP_ASYNC_SIZE for the prefix "APP".
+func (c *Config) LoadEnv(prefix string) error {
+  return loadEnv(reflect.ValueOf(c).Elem(), prefix+"_")
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+func loadEnv(v reflect.Value, prefix string) error {
+  t := v.Type()
+  for i := 0; i < t.NumField(); i++ {
+    tag := t.Field(i).Tag.Get("env")
+    if tag == "" {
+      continue
+    }
+    name := prefix + tag
+    f := v.Field(i)
+    if f.Kind() == reflect.Ptr && f.Type().Elem().Kind() == reflect.Struct {
+      // only allocated when one of its variables is set
+      sub := reflect.New(f.Type().Elem())
+      if !f.IsNil() {
+        sub.Elem().Set(f.Elem())
+      }
+      if hasEnvPrefix(name + "_") {
+        if err := loadEnv(sub.Elem(), name+"_"); err != nil {
+          return err
+        }
+        f.Set(sub)
+      }
+      continue
+    }
+    s, ok := os.LookupEnv(name)
+    if !ok {
+      continue
+    }
+    if f.Kind() == reflect.Ptr {
+      // an optional value
+      f.Set(reflect.New(f.Type().Elem()))
+      f = f.Elem()
+    }
+    var err error
+    switch {
+    case f.Addr().Type().Implements(textUnmarshalerType):
+      err = f.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+    case f.Kind() == reflect.String:
+      f.SetString(s)
+    case f.Kind() == reflect.Bool:
+      var b bool
+      if b, err = strconv.ParseBool(s); err == nil {
+        f.SetBool(b)
+      }
+    case f.Kind() == reflect.Int:
+      var n int64
+      if n, err = strconv.ParseInt(s, 10, 0); err == nil {
+        f.SetInt(n)
+      }
+    default:
+      err = json.Unmarshal([]byte(s), f.Addr().Interface())
+    }
+    if err != nil {
+      return fmt.Errorf("log: %s: %v", name, err)
+    }
+  }
+  return nil
+}
+
+func hasEnvPrefix(prefix string) bool {
+  for _, kv := range os.Environ() {
+    if strings.HasPrefix(kv, prefix) {
+      return true
+    }
+  }
+  return false
+}
+
+// NewFromConfig validates c and returns the logger it describes. The
+// returned error lists every invalid setting.
+func NewFromConfig(c Config) (*Logger, error) {
+  decorators, err := c.decorators()
+  if err != nil {
+    return nil, err
+  }
+  return NewLogInstance(decorators...), nil
+}
+
+// configErrors collects the errors of a config, prefixed by the setting.
+type configErrors []error
+
+func (errs *configErrors) add(field string, err error) {
+  if err != nil {
+    *errs = append(*errs, fmt.Errorf("%s: %v", field, err))
+  }
+}
+
+func (errs configErrors) err() error {
+  if len(errs) == 0 {
+    return nil
+  }
+  return fmt.Errorf("log: invalid config: %w", errors.Join(errs...))
+}
+
+// decorators converts c into decorators, opening the sinks once the whole
+// config is valid.
+func (c Config) decorators() ([]func(Logger) Logger, error) {
+  var errs configErrors
+  var decorators []func(Logger) Logger
+
+  if _, ok := levelName[c.Level]; !ok {
+    errs.add("level", fmt.Errorf("unknown level %d", int(c.Level)))
+  }
+  level := c.Level
+  decorators = append(decorators, func(l Logger) Logger {
+    l.level = level
+    return l
+  })
+  if c.LevelRules != "" {
+    _, err := parseLevelRules(c.LevelRules)
+    errs.add("level_rules", err)
+    decorators = append(decorators, LevelRules(c.LevelRules))
+  }
+  enc, err := configEncoder(c.Encoder)
+  errs.add("encoder", err)
+  decorators = append(decorators, LogEncoder(enc))
+  flags, err := configFlags(c.Caller)
+  errs.add("caller", err)
+  decorators = append(decorators, LogFlags(flags))
+  switch c.TimeLayout {
+  case "":
+  case "rfc3339":
+    decorators = append(decorators, LogTimeLayout(time.RFC3339))
+  case "rfc3339nano":
+    decorators = append(decorators, LogTimeLayout(time.RFC3339Nano))
+  default:
+    decorators = append(decorators, LogTimeLayout(c.TimeLayout))
+  }
+  if c.UTC {
+    decorators = append(decorators, LogUTC)
+  }
+  if c.Stdout {
+    decorators = append(decorators, AlsoStdout)
+  }
+  if c.File != nil {
+    decorators = append(decorators, c.File.decorators("file", c.UTC, &errs)...)
+  }
+  if c.Async != nil {
+    policy, err := configOverflow(c.Async.Overflow)
+    errs.add("async.overflow", err)
+    if c.Async.Size <= 0 {
+      errs.add("async.size", errors.New("must be positive"))
+    }
+    decorators = append(decorators, Async(c.Async.Size, policy))
+  }
+  if s := c.Sampling; s != nil {
+    interval, err := time.ParseDuration(s.Interval)
+    errs.add("sampling.interval", err)
+    if err == nil && interval <= 0 {
+      errs.add("sampling.interval", errors.New("must be positive"))
+    }
+    if s.First < 0 {
+      errs.add("sampling.first", errors.New("must not be negative"))
+    }
+    decorators = append(decorators, Sample(s.First, s.Thereafter, interval))
+    if s.Bypass != nil {
+      decorators = append(decorators, SampleBypass(*s.Bypass))
+    }
+  }
+  opens := make([]func() (Sink, error), len(c.Sinks))
+  for i, sc := range c.Sinks {
+    opens[i] = sc.open(fmt.Sprintf("sinks[%d]", i), c.UTC, &errs)
+  }
+  if err := errs.err(); err != nil {
+    return nil, err
+  }
+
+  var sinks []Sink
+  for i, open := range opens {
+    s, err := open()
+    if err != nil {
+      for _, s := range sinks {
+        s.Close()
+      }
+      return nil, fmt.Errorf("log: sinks[%d]: %v", i, err)
+    }
+    sinks = append(sinks, s)
+    decorators = append(decorators, LogSink(s, c.Sinks[i].Level))
+  }
+  return decorators, nil
+}
+
+// decorators returns the decorators of the file, rotated on UTC boundaries
+// if utc is set.
+func (fc *FileConfig) decorators(field string, utc bool, errs *configErrors) []func(Logger) Logger {
+  if fc.Path == "" {
+    errs.add(field+".path", errors.New("missing"))
+  }
+  decorators := []func(Logger) Logger{LogFilePath(fc.Path, fc.Name)}
+  loc := time.Local
+  if utc {
+    decorators = append(decorators, LogUTC)
+    loc = time.UTC
+  }
+  switch fc.Rotate {
+  case "":
+  case "minute":
+    decorators = append(decorators, EveryMinute)
+  case "hour":
+    decorators = append(decorators, EveryHour)
+  case "day":
+    decorators = append(decorators, EveryDay)
+  default:
+    d, err := time.ParseDuration(fc.Rotate)
+    if err == nil && d <= 0 {
+      err = errors.New("must be positive")
+    }
+    errs.add(field+".rotate", err)
+    if err == nil {
+      decorators = append(decorators, RotateBy(CalendarRotation(d, loc)))
+    }
+  }
+  if fc.MaxSize != "" {
+    n, err := parseSize(fc.MaxSize)
+    errs.add(field+".max_size", err)
+    decorators = append(decorators, MaxSize(n))
+  }
+  if fc.MaxBackups < 0 {
+    errs.add(field+".max_backups", errors.New("must not be negative"))
+  } else if fc.MaxBackups > 0 {
+    decorators = append(decorators, MaxBackups(fc.MaxBackups))
+  }
+  if fc.MaxAge != "" {
+    d, err := time.ParseDuration(fc.MaxAge)
+    errs.add(field+".max_age", err)
+    decorators = append(decorators, MaxAge(d))
+  }
+  if fc.MaxTotalSize != "" {
+    n, err := parseSize(fc.MaxTotalSize)
+    errs.add(field+".max_total_size", err)
+    decorators = append(decorators, MaxTotalSize(n))
+  }
+  if fc.Compress {
+    decorators = append(decorators, Compress(GzipCompressor))
+  }
+  return decorators
+}
+
+// open validates sc and returns the function opening the sink.
+func (sc SinkConfig) open(field string, utc bool, errs *configErrors) func() (Sink, error) {
+  enc, err := configEncoder(sc.Encoder)
+  errs.add(field+".encoder", err)
+  if _, ok := levelName[sc.Level]; !ok {
+    errs.add(field+".level", fmt.Errorf("unknown level %d", int(sc.Level)))
+  }
+  switch sc.Type {
+  case "stderr":
+    return func() (Sink, error) {
+      return NewWriterSink(os.Stderr, enc), nil
+    }
+  case "stdout":
+    return func() (Sink, error) {
+      return NewWriterSink(os.Stdout, enc), nil
+    }
+  case "file":
+    if sc.File == nil {
+      errs.add(field+".file", errors.New("missing"))
+      return nil
+    }
+    decorators := append(sc.File.decorators(field+".file", utc, errs), LogEncoder(enc))
+    return func() (Sink, error) {
+      return NewFileSink(decorators...)
+    }
+  case "syslog":
+    conf := SyslogConfig{Network: sc.Network, Addr: sc.Addr, AppName: sc.AppName}
+    if sc.Encoder != "" {
+      conf.Encoder = enc
+    }
+    switch sc.Format {
+    case "", "rfc5424":
+    case "rfc3164":
+      conf.Format = RFC3164
+    default:
+      errs.add(field+".format", fmt.Errorf("unknown format %q", sc.Format))
+    }
+    if sc.Facility != "" {
+      facility, ok := facilityName[sc.Facility]
+      if !ok {
+        errs.add(field+".facility", fmt.Errorf("unknown facility %q", sc.Facility))
+      }
+      conf.Facility = facility
+    }
+    return func() (Sink, error) {
+      return NewSyslogSink(conf)
+    }
+  case "shipper":
+    conf := ShipperConfig{URL: sc.URL, BatchSize: sc.BatchSize, SpillDir: sc.SpillDir}
+    if sc.Encoder != "" {
+      conf.Encoder = enc
+    }
+    if sc.URL == "" {
+      errs.add(field+".url", errors.New("missing"))
+    }
+    if sc.FlushInterval != "" {
+      d, err := time.ParseDuration(sc.FlushInterval)
+      errs.add(field+".flush_interval", err)
+      conf.FlushInterval = d
+    }
+    return func() (Sink, error) {
+      return NewShipperSink(conf)
+    }
+  default:
+    errs.add(field+".type", fmt.Errorf("unknown sink type %q", sc.Type))
+    return nil
+  }
+}
+
+var facilityName = map[string]Facility{
+  "user":   FacilityUser,
+  "daemon": FacilityDaemon,
+  "auth":   FacilityAuth,
+  "local0": FacilityLocal0,
+  "local1": FacilityLocal1,
+  "local2": FacilityLocal2,
+  "local3": FacilityLocal3,
+  "local4": FacilityLocal4,
+  "local5": FacilityLocal5,
+  "local6": FacilityLocal6,
+  "local7": FacilityLocal7,
+}
+
+func configFlags(caller string) (int32, error) {
+  switch caller {
+  case "", "none":
+    return 0, nil
+  case "func":
+    return Lfunc, nil
+  case "file":
+    return Lfunc | Lfile, nil
+  case "line":
+    return Lfunc | Lfile | Lline, nil
+  }
+  return 0, fmt.Errorf("unknown caller %q", caller)
+}
+
+func configEncoder(name string) (Encoder, error) {
+  switch name {
+  case "", "text":
+    return TextEncoder, nil
+  case "json":
+    return JSONEncoder, nil
+  case "logfmt":
+    return LogfmtEncoder, nil
+  }
+  return TextEncoder, fmt.Errorf("unknown encoder %q", name)
+}
+
+func configOverflow(s string) (OverflowPolicy, error) {
+  switch s {
+  case "", "block":
+    return Block, nil
+  case "drop_newest":
+    return DropNewest, nil
+  case "drop_oldest":
+    return DropOldest, nil
+  }
+  if strings.HasPrefix(s, "drop_below:") {
+    level, err := ParseLevel(s[len("drop_below:"):])
+    return DropBelow(level), err
+  }
+  return Block, fmt.Errorf("unknown overflow policy %q", s)
+}
+
+// parseSize parses a number of bytes with an optional KB, MB or GB suffix.
+func parseSize(s string) (int64, error) {
+  unit := int64(1)
+  num := strings.ToUpper(strings.TrimSpace(s))
+  for _, u := range []struct {
+    suffix string
+    size   int64
+  }{{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"B", 1}} {
+    if strings.HasSuffix(num, u.suffix) {
+      num, unit = strings.TrimSpace(strings.TrimSuffix(num, u.suffix)), u.size
+      break
+    }
+  }
+  n, err := strconv.ParseInt(num, 10, 64)
+  if err != nil || n < 0 {
+    return 0, fmt.Errorf("invalid size %q", s)
+  }
+  return n * unit, nil
+}
@@ -0,0 +1,109 @@
+package log
+
+import (
+  "encoding/json"
+  "os"
+  "path"
+  "strings"
+  "testing"
+  "time"
+)
+
+func TestNewFromConfig(t *testing.T) {
+  dir := t.TempDir()
+  data := `{
+  "level": "info",
+  "encoder": "logfmt",
+  "file": {"path": "` + dir + `", "name": "main.log", "max_size": "1MB", "max_backups": 3},
+  "sinks": [{"type": "file", "level": "error", "encoder": "json", "file": {"path": "` + dir + `", "name": "errors.log"}}]
+}`
+  var c Config
+  if err := json.Unmarshal([]byte(data), &c); err != nil {
+    t.Fatal(err)
+  }
+  t.Setenv("APP_LEVEL", "debug")
+  t.Setenv("APP_CALLER", "func")
+  t.Setenv("APP_FILE_MAX_BACKUPS", "5")
+  if err := c.LoadEnv("APP"); err != nil {
+    t.Fatal(err)
+  }
+  if c.Level != DEBUG || c.Caller != "func" || c.File.MaxBackups != 5 || c.File.Name != "main.log" {
+    t.Fatalf("unexpected config %+v %+v", c, c.File)
+  }
+  if b, err := json.Marshal(c.Level); err != nil || string(b) != `"DEBUG"` {
+    t.Errorf("marshalled level %s %v", b, err)
+  }
+
+  inst, err := NewFromConfig(c)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if inst.maxSize != 1<<20 || inst.retention.maxBackups != 5 {
+    t.Errorf("file settings not applied")
+  }
+  l := NewAdaptorFromInstance(inst, 3)
+  l.Debugf("debug")
+  l.Errorf("failed")
+  l.Stop()
+
+  mainLog, _ := os.ReadFile(path.Join(dir, "main.log"))
+  if !strings.Contains(string(mainLog), "level=DBG func=log.TestNewFromConfig msg=debug") {
+    t.Errorf("unexpected main log %q", mainLog)
+  }
+  errs, _ := os.ReadFile(path.Join(dir, "errors.log"))
+  if lines := strings.Split(strings.TrimSpace(string(errs)), "\n"); len(lines) != 1 ||
+    !strings.Contains(lines[0], `"msg":"failed"`) {
+    t.Errorf("unexpected error log %q", errs)
+  }
+}
+
+func TestConfigValidation(t *testing.T) {
+  var c Config
+  if err := json.Unmarshal([]byte(`{"level":"loud"}`), &c); err == nil {
+    t.Error("unknown level accepted")
+  }
+  c = Config{
+    Encoder: "xml",
+    File:    &FileConfig{Rotate: "weekly", MaxSize: "10XB"},
+    Async:   &AsyncConfig{Overflow: "drop_all"},
+    Sinks:   []SinkConfig{{Type: "kafka"}, {Type: "syslog", Facility: "mail"}},
+  }
+  _, err := NewFromConfig(c)
+  if err == nil {
+    t.Fatal("invalid config accepted")
+  }
+  for _, want := range []string{
+    "encoder:", "file.path:", "file.rotate:", "file.max_size:", "async.overflow:",
+    "async.size:", `sinks[0].type: unknown sink type "kafka"`, "sinks[1].facility:",
+  } {
+    if !strings.Contains(err.Error(), want) {
+      t.Errorf("%q missing in %v", want, err)
+    }
+  }
+}
+
+func TestConfigUTCAndBypass(t *testing.T) {
+  c := Config{
+    UTC:      true,
+    File:     &FileConfig{Path: t.TempDir(), Rotate: "90m"},
+    Sampling: &SamplingConfig{First: 1, Interval: "1s"},
+  }
+  t.Setenv("APP_SAMPLING_BYPASS", "trace")
+  if err := c.LoadEnv("APP"); err != nil {
+    t.Fatal(err)
+  }
+  if c.Sampling.Bypass == nil || *c.Sampling.Bypass != TRACE {
+    t.Fatalf("bypass %v", c.Sampling.Bypass)
+  }
+  inst, err := NewFromConfig(c)
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer inst.Stop()
+  if r, ok := inst.rotation.(calendarRotation); !ok || r.loc != time.UTC {
+    t.Errorf("rotation %+v", inst.rotation)
+  }
+  if inst.state.sampler.conf.bypass != TRACE {
+    t.Errorf("sampling bypass %v", inst.state.sampler.conf.bypass)
+  }
+}
@@ -0,0 +1,140 @@
+package log
+
+import (
+  "bytes"
+  "io"
+  "os"
+  "path"
+  "strconv"
+)
+
+const (
+  ansiReset = "\x1b[0m"
+  ansiDim   = "\x1b[2m"
+  ansiCyan  = "\x1b[36m"
+)
+
+var levelColor = map[LogLevel]string{
+  TRACE: "\x1b[90m",   // gray
+  DEBUG: "\x1b[34m",   // blue
+  INFO:  "\x1b[32m",   // green
+  WARN:  "\x1b[33m",   // yellow
+  ERROR: "\x1b[31m",   // red
+  FATAL: "\x1b[1;35m", // bold magenta
+}
+
+// ConsoleConfig configures a console encoder.
+type ConsoleConfig struct {
+  // Color colors the level tag, ColorCaller and ColorFields the caller
+  // and the field keys.
+  Color       bool
+  ColorCaller bool
+  ColorFields bool
+  // CallerWidth pads the caller to align the messages.
+  CallerWidth int
+}
+
+// defaultConsole is used for the terminal outputs of a logger without an
+// encoder.
+var defaultConsole = ConsoleConfig{Color: true, ColorCaller: true, ColorFields: true, CallerWidth: 32}
+
+// NewConsoleEncoder returns an encoder for humans reading a terminal,
+// "15:04:05.000 INF [pkg.Fn] (file.go:12)  message k=v".
+func NewConsoleEncoder(conf ConsoleConfig) Encoder {
+  return EncoderFunc(func(buf *bytes.Buffer, r *Record) {
+    encodeConsole(buf, r, &conf)
+  })
+}
+
+func encodeConsole(buf *bytes.Buffer, r *Record, conf *ConsoleConfig) {
+  if !r.Time.IsZero() {
+    colored(buf, conf.Color, ansiDim, r.timeString("15:04:05.000"))
+    buf.WriteByte(' ')
+  }
+  if r.raw {
+    buf.WriteString(r.Message)
+    buf.WriteByte('\n')
+    return
+  }
+  colored(buf, conf.Color, levelColor[r.Level], tagName[r.Level])
+
+  start := buf.Len()
+  if conf.ColorCaller && (r.Func != "" || r.File != "") {
+    buf.WriteString(ansiDim)
+  }
+  if r.Func != "" {
+    buf.WriteString(" [")
+    buf.WriteString(path.Base(r.Func))
+    buf.WriteByte(']')
+  }
+  if r.File != "" {
+    buf.WriteString(" (")
+    buf.WriteString(path.Base(r.File))
+    if r.Line > 0 {
+      buf.WriteByte(':')
+      buf.WriteString(strconv.Itoa(r.Line))
+    }
+    buf.WriteByte(')')
+  }
+  width := buf.Len() - start
+  if conf.ColorCaller && (r.Func != "" || r.File != "") {
+    width -= len(ansiDim)
+    buf.WriteString(ansiReset)
+  }
+  if r.Func != "" || r.File != "" {
+    for ; width < conf.CallerWidth; width++ {
+      buf.WriteByte(' ')
+    }
+  }
+
+  buf.WriteString("  ")
+  buf.WriteString(r.Message)
+  walkFields("", r.Fields, func(key string, v interface{}) {
+    buf.WriteByte(' ')
+    colored(buf, conf.ColorFields, ansiCyan, key+"=")
+    buf.WriteString(quoteIfNeeded(fieldString(v)))
+  })
+  buf.WriteByte('\n')
+}
+
+// colored writes s, in color if on.
+func colored(buf *bytes.Buffer, on bool, color, s string) {
+  if !on || color == "" {
+    buf.WriteString(s)
+    return
+  }
+  buf.WriteString(color)
+  buf.WriteString(s)
+  buf.WriteString(ansiReset)
+}
+
+// IsTerminal reports whether w is a terminal.
+func IsTerminal(w io.Writer) bool {
+  f, ok := w.(*os.File)
+  if !ok {
+    return false
+  }
+  info, err := f.Stat()
+  return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// ColorEnabled reports whether colored lines should be written to w: when w
+// is a terminal or FORCE_COLOR is set, unless NO_COLOR is set.
+func ColorEnabled(w io.Writer) bool {
+  if os.Getenv("NO_COLOR") != "" {
+    return false
+  }
+  if force := os.Getenv("FORCE_COLOR"); force != "" && force != "0" && force != "false" {
+    return true
+  }
+  return IsTerminal(w)
+}
+
+// consoleEncoder returns the encoder of a logger output to w: the encoder
+// set for the logger, else the console encoder if colors are enabled for w.
+func (l *Logger) consoleEncoder(w io.Writer) Encoder {
+  if l.encoder == nil && ColorEnabled(w) {
+    return NewConsoleEncoder(defaultConsole)
+  }
+  return l.encoder
+}
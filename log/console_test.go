@@ -0,0 +1,54 @@
+package log
+
+import (
+  "bytes"
+  "os"
+  "testing"
+  "time"
+)
+
+func TestConsoleEncoder(t *testing.T) {
+  r := &Record{
+    Time:    time.Date(2024, 5, 1, 13, 4, 5, 0, time.UTC),
+    Level:   WARN,
+    Func:    "github.com/x/pkg.Run",
+    File:    "/src/pkg/run.go",
+    Line:    12,
+    Message: "slow",
+    Fields:  []Field{Duration("took", time.Second)},
+  }
+  var buf bytes.Buffer
+  NewConsoleEncoder(ConsoleConfig{CallerWidth: 24}).Encode(&buf, r)
+  if want := "13:04:05.000 WRN [pkg.Run] (run.go:12)    slow took=1s\n"; buf.String() != want {
+    t.Errorf("got %q, want %q", buf.String(), want)
+  }
+
+  buf.Reset()
+  NewConsoleEncoder(defaultConsole).Encode(&buf, r)
+  want := "\x1b[2m13:04:05.000\x1b[0m \x1b[33mWRN\x1b[0m\x1b[2m [pkg.Run] (run.go:12)\x1b[0m" +
+    "            slow \x1b[36mtook=\x1b[0m1s\n"
+  if buf.String() != want {
+    t.Errorf("got %q, want %q", buf.String(), want)
+  }
+}
+
+func TestColorEnabled(t *testing.T) {
+  f, err := os.CreateTemp(t.TempDir(), "out")
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer f.Close()
+  t.Setenv("NO_COLOR", "")
+  t.Setenv("FORCE_COLOR", "")
+  if ColorEnabled(f) {
+    t.Error("colors enabled for a file")
+  }
+  t.Setenv("FORCE_COLOR", "1")
+  if !ColorEnabled(f) {
+    t.Error("FORCE_COLOR ignored")
+  }
+  t.Setenv("NO_COLOR", "1")
+  if ColorEnabled(f) {
+    t.Error("NO_COLOR ignored")
+  }
+}
@@ -0,0 +1,136 @@
+package log
+
+import (
+  "context"
+  "fmt"
+  "os"
+)
+
+// Keys of the fields commonly attached to a context.
+const (
+  RequestIDKey = "request_id"
+  TraceIDKey   = "trace_id"
+  SpanIDKey    = "span_id"
+  TenantKey    = "tenant"
+)
+
+type contextFieldsKey struct{}
+
+// ContextExtractor returns the fields to log for ctx.
+type ContextExtractor func(ctx context.Context) []Field
+
+// WithContext returns a copy of ctx carrying the given key-value pairs after
+// the ones it already carries. They are emitted by the ...c logging methods.
+func WithContext(ctx context.Context, kv ...interface{}) context.Context {
+  return context.WithValue(ctx, contextFieldsKey{}, mergeFields(FieldsFromContext(ctx), fieldsFromKV(kv)))
+}
+
+// FieldsFromContext returns the fields attached to ctx by WithContext.
+func FieldsFromContext(ctx context.Context) []Field {
+  if ctx == nil {
+    return nil
+  }
+  fields, _ := ctx.Value(contextFieldsKey{}).([]Field)
+  return fields
+}
+
+// ContextValue returns an extractor logging the value stored in the context
+// under ctxKey, typically by another middleware, as field key.
+func ContextValue(ctxKey interface{}, key string) ContextExtractor {
+  return func(ctx context.Context) []Field {
+    if v := ctx.Value(ctxKey); v != nil {
+      return []Field{{Key: key, Value: v}}
+    }
+    return nil
+  }
+}
+
+// LogContextExtractor returns a function to add an extractor of context
+// fields, called after the fields attached by WithContext are collected.
+func LogContextExtractor(e ContextExtractor) func(Logger) Logger {
+  return func(l Logger) Logger {
+    extractors := make([]ContextExtractor, len(l.extractors), len(l.extractors)+1)
+    copy(extractors, l.extractors)
+    l.extractors = append(extractors, e)
+    return l
+  }
+}
+
+// contextFields returns the fields to log for ctx.
+func (l *Logger) contextFields(ctx context.Context) []Field {
+  if ctx == nil {
+    return nil
+  }
+  fields := FieldsFromContext(ctx)
+  for _, e := range l.extractors {
+    fields = mergeFields(fields, e(ctx))
+  }
+  return fields
+}
+
+func (l *LogAdaptor) ctxFields(ctx context.Context) []Field {
+  return mergeFields(l.fields, l.current().contextFields(ctx))
+}
+
+// Tracec prints formatted trace log with the fields of ctx.
+func (l *LogAdaptor) Tracec(ctx context.Context, format string, v ...interface{}) {
+  l.current().doPrintfN(l.calldepth, TRACE, l.ctxFields(ctx), format, v...)
+}
+
+// Debugc prints formatted debug log with the fields of ctx.
+func (l *LogAdaptor) Debugc(ctx context.Context, format string, v ...interface{}) {
+  l.current().doPrintfN(l.calldepth, DEBUG, l.ctxFields(ctx), format, v...)
+}
+
+// Infoc prints formatted info log with the fields of ctx.
+func (l *LogAdaptor) Infoc(ctx context.Context, format string, v ...interface{}) {
+  l.current().doPrintfN(l.calldepth, INFO, l.ctxFields(ctx), format, v...)
+}
+
+// Warnc prints formatted warn log with the fields of ctx.
+func (l *LogAdaptor) Warnc(ctx context.Context, format string, v ...interface{}) {
+  l.current().doPrintfN(l.calldepth, WARN, l.ctxFields(ctx), format, v...)
+}
+
+// Errorc prints formatted error log with the fields of ctx.
+func (l *LogAdaptor) Errorc(ctx context.Context, format string, v ...interface{}) {
+  l.current().doPrintfN(l.calldepth, ERROR, l.ctxFields(ctx), format, v...)
+}
+
+// Fatalc prints formatted fatal log with the fields of ctx and exits.
+func (l *LogAdaptor) Fatalc(ctx context.Context, format string, v ...interface{}) {
+  l.current().doPrintfN(l.calldepth, FATAL, l.ctxFields(ctx), format, v...)
+  os.Exit(1)
+}
+
+// Tracec prints formatted trace log with the fields of ctx.
+func Tracec(ctx context.Context, format string, v ...interface{}) {
+  pkgAdaptor().Tracec(ctx, format, v...)
+}
+
+// Debugc prints formatted debug log with the fields of ctx.
+func Debugc(ctx context.Context, format string, v ...interface{}) {
+  pkgAdaptor().Debugc(ctx, format, v...)
+}
+
+// Infoc prints formatted info log with the fields of ctx.
+func Infoc(ctx context.Context, format string, v ...interface{}) {
+  pkgAdaptor().Infoc(ctx, format, v...)
+}
+
+// Warnc prints formatted warn log with the fields of ctx.
+func Warnc(ctx context.Context, format string, v ...interface{}) {
+  pkgAdaptor().Warnc(ctx, format, v...)
+}
+
+// Errorc prints formatted error log with the fields of ctx.
+func Errorc(ctx context.Context, format string, v ...interface{}) {
+  pkgAdaptor().Errorc(ctx, format, v...)
+}
+
+// Fatalc prints formatted fatal log with the fields of ctx and exits.
+func Fatalc(ctx context.Context, format string, v ...interface{}) {
+  fatalWithoutDefault(fmt.Sprintf(format, v...), FieldsFromContext(ctx))
+  pkgAdaptor().Fatalc(ctx, format, v...)
+  os.Exit(1)
+}
@@ -0,0 +1,44 @@
+package log
+
+import (
+  "context"
+  "os"
+  "path"
+  "strings"
+  "testing"
+)
+
+type traceKey struct{}
+
+func TestContextFields(t *testing.T) {
+  dir := t.TempDir()
+  inst := NewLogInstance(LogFilePath(dir, "ctx.log"), LogFlags(Lfile|Lline),
+    LogContextExtractor(ContextValue(traceKey{}, TraceIDKey)))
+  l := NewAdaptorFromInstance(inst, 3)
+
+  ctx := WithContext(context.Background(), RequestIDKey, "r-1")
+  ctx = WithContext(ctx, TenantKey, "acme")
+  l.With("svc", "api").Infoc(ctx, "hello %d", 1)
+  l.Warnc(context.WithValue(ctx, traceKey{}, "t-9"), "traced")
+  l.Errorc(context.Background(), "bare")
+  l.Stop()
+
+  data, err := os.ReadFile(path.Join(dir, "ctx.log"))
+  if err != nil {
+    t.Fatal(err)
+  }
+  lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+  if len(lines) != 3 {
+    t.Fatalf("got %d lines: %q", len(lines), data)
+  }
+  if !strings.HasSuffix(lines[0], "hello 1 svc=api request_id=r-1 tenant=acme") ||
+    !strings.Contains(lines[0], "(context_test.go:") {
+    t.Errorf("unexpected line %q", lines[0])
+  }
+  if !strings.HasSuffix(lines[1], "traced request_id=r-1 tenant=acme trace_id=t-9") {
+    t.Errorf("unexpected line %q", lines[1])
+  }
+  if !strings.HasSuffix(lines[2], ": bare") {
+    t.Errorf("unexpected line %q", lines[2])
+  }
+}
@@ -0,0 +1,325 @@
+package log
+
+import (
+  "bytes"
+  "encoding/json"
+  "fmt"
+  "path"
+  "strconv"
+  "strings"
+  "sync"
+  "time"
+  "unicode/utf8"
+)
+
+// Record is a single log entry handed to an Encoder.
+type Record struct {
+  Time    time.Time
+  Level   LogLevel
+  Func    string // full function name, empty unless flags are set
+  File    string // full file path, empty unless Lfile or Lline is set
+  Line    int    // line number, zero unless Lline is set
+  Message string
+  Fields  []Field
+
+  timeLayout string // set by the logger, see LogTimeLayout
+  raw        bool   // untagged line written by Printf
+}
+
+// Encoder renders a Record into buf. The encoded record must end with a
+// newline.
+type Encoder interface {
+  Encode(buf *bytes.Buffer, r *Record)
+}
+
+// EncoderFunc adapts an ordinary function to the Encoder interface.
+type EncoderFunc func(buf *bytes.Buffer, r *Record)
+
+// Encode calls f(buf, r).
+func (f EncoderFunc) Encode(buf *bytes.Buffer, r *Record) {
+  f(buf, r)
+}
+
+var (
+  // TextEncoder is the default encoder, producing
+  // "2006/01/02 15:04:05.000000 INF [pkg.Fn] (file.go:12): message k=v".
+  TextEncoder Encoder = EncoderFunc(encodeText)
+  // JSONEncoder produces one JSON object per line.
+  JSONEncoder Encoder = EncoderFunc(encodeJSON)
+)
+
+var levelName = map[LogLevel]string{
+  TRACE: "TRACE",
+  DEBUG: "DEBUG",
+  INFO:  "INFO",
+  WARN:  "WARN",
+  ERROR: "ERROR",
+  FATAL: "FATAL",
+}
+
+// String returns the upper case name of the level.
+func (lv LogLevel) String() string {
+  if name, ok := levelName[lv]; ok {
+    return name
+  }
+  return "LEVEL(" + strconv.Itoa(int(lv)) + ")"
+}
+
+// ParseLevel returns the level named s, such as "debug", "WARN" or "ERR".
+func ParseLevel(s string) (LogLevel, error) {
+  name := strings.ToUpper(strings.TrimSpace(s))
+  if name == "WARNING" {
+    return WARN, nil
+  }
+  for lv, n := range levelName {
+    if name == n || name == tagName[lv] {
+      return lv, nil
+    }
+  }
+  return 0, fmt.Errorf("log: unknown level %q", s)
+}
+
+var bufferPool = sync.Pool{
+  New: func() interface{} {
+    return new(bytes.Buffer)
+  },
+}
+
+func getBuffer() *bytes.Buffer {
+  buf := bufferPool.Get().(*bytes.Buffer)
+  buf.Reset()
+  return buf
+}
+
+func putBuffer(buf *bytes.Buffer) {
+  // don't keep huge buffers around
+  if buf.Cap() > 64<<10 {
+    return
+  }
+  bufferPool.Put(buf)
+}
+
+func encodeText(buf *bytes.Buffer, r *Record) {
+  if !r.Time.IsZero() {
+    buf.WriteString(r.timeString("2006/01/02 15:04:05.000000"))
+    buf.WriteByte(' ')
+  }
+  if r.raw {
+    buf.WriteString(r.Message)
+    buf.WriteByte('\n')
+    return
+  }
+  buf.WriteString(tagName[r.Level])
+  if r.Func != "" {
+    buf.WriteString(" [")
+    buf.WriteString(path.Base(r.Func))
+    buf.WriteByte(']')
+  }
+  if r.File != "" {
+    buf.WriteString(" (")
+    buf.WriteString(path.Base(r.File))
+    if r.Line > 0 {
+      buf.WriteByte(':')
+      buf.WriteString(strconv.Itoa(r.Line))
+    }
+    buf.WriteByte(')')
+  }
+  buf.WriteString(": ")
+  buf.WriteString(r.Message)
+  appendFields(buf, r.Fields)
+  buf.WriteByte('\n')
+}
+
+func encodeJSON(buf *bytes.Buffer, r *Record) {
+  buf.WriteByte('{')
+  if !r.Time.IsZero() {
+    buf.WriteString(`"ts":`)
+    if r.timeLayout == EpochMillis {
+      buf.WriteString(r.timeString(""))
+    } else {
+      appendJSONString(buf, r.timeString("2006-01-02T15:04:05.000000Z07:00"))
+    }
+    buf.WriteByte(',')
+  }
+  if r.raw {
+    buf.WriteString(`"msg":`)
+    appendJSONString(buf, r.Message)
+    buf.WriteString("}\n")
+    return
+  }
+  buf.WriteString(`"level":`)
+  appendJSONString(buf, r.Level.String())
+  if r.File != "" {
+    caller := path.Base(r.File)
+    if r.Line > 0 {
+      caller += ":" + strconv.Itoa(r.Line)
+    }
+    buf.WriteString(`,"caller":`)
+    appendJSONString(buf, caller)
+  }
+  if r.Func != "" {
+    buf.WriteString(`,"func":`)
+    appendJSONString(buf, path.Base(r.Func))
+  }
+  buf.WriteString(`,"msg":`)
+  appendJSONString(buf, r.Message)
+  for _, f := range r.Fields {
+    buf.WriteByte(',')
+    appendJSONField(buf, f)
+  }
+  buf.WriteString("}\n")
+}
+
+func appendJSONField(buf *bytes.Buffer, f Field) {
+  appendJSONString(buf, f.Key)
+  buf.WriteByte(':')
+  appendJSONValue(buf, f.Value)
+}
+
+func appendJSONValue(buf *bytes.Buffer, v interface{}) {
+  switch v := v.(type) {
+  case nil:
+    buf.WriteString("null")
+  case string:
+    appendJSONString(buf, v)
+  case bool:
+    buf.WriteString(strconv.FormatBool(v))
+  case int:
+    buf.WriteString(strconv.Itoa(v))
+  case int64:
+    buf.WriteString(strconv.FormatInt(v, 10))
+  case uint64:
+    buf.WriteString(strconv.FormatUint(v, 10))
+  case error:
+    appendJSONString(buf, v.Error())
+  case time.Time:
+    appendJSONString(buf, v.Format(time.RFC3339Nano))
+  case time.Duration:
+    appendJSONString(buf, v.String())
+  case []Field:
+    // a group of fields
+    buf.WriteByte('{')
+    for i, f := range v {
+      if i > 0 {
+        buf.WriteByte(',')
+      }
+      appendJSONField(buf, f)
+    }
+    buf.WriteByte('}')
+  default:
+    appendJSONMarshal(buf, v)
+  }
+}
+
+func appendJSONMarshal(buf *bytes.Buffer, v interface{}) {
+  b, err := json.Marshal(v)
+  if err != nil {
+    appendJSONString(buf, fieldString(v))
+    return
+  }
+  buf.Write(b)
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString writes s as a quoted JSON string.
+func appendJSONString(buf *bytes.Buffer, s string) {
+  buf.WriteByte('"')
+  start := 0
+  for i := 0; i < len(s); {
+    c := s[i]
+    if c >= utf8.RuneSelf {
+      r, size := utf8.DecodeRuneInString(s[i:])
+      if r == utf8.RuneError && size == 1 {
+        buf.WriteString(s[start:i])
+        buf.WriteString(`\ufffd`)
+        i += size
+        start = i
+        continue
+      }
+      i += size
+      continue
+    }
+    if c >= 0x20 && c != '"' && c != '\\' {
+      i++
+      continue
+    }
+    buf.WriteString(s[start:i])
+    switch c {
+    case '"', '\\':
+      buf.WriteByte('\\')
+      buf.WriteByte(c)
+    case '\n':
+      buf.WriteString(`\n`)
+    case '\r':
+      buf.WriteString(`\r`)
+    case '\t':
+      buf.WriteString(`\t`)
+    default:
+      buf.WriteString(`\u00`)
+      buf.WriteByte(hexDigits[c>>4])
+      buf.WriteByte(hexDigits[c&0xf])
+    }
+    i++
+    start = i
+  }
+  buf.WriteString(s[start:])
+  buf.WriteByte('"')
+}
+
+// LogEncoder returns a function to set the encoder of the log lines.
+func LogEncoder(enc Encoder) func(Logger) Logger {
+  return func(l Logger) Logger {
+    l.encoder = enc
+    return l
+  }
+}
+
+// EpochMillis is a time layout for LogTimeLayout writing the number of
+// milliseconds since the Unix epoch.
+const EpochMillis = "epochmillis"
+
+// timeString formats the record time with the layout set by the logger, or
+// layout.
+func (r *Record) timeString(layout string) string {
+  if r.timeLayout != "" {
+    layout = r.timeLayout
+  }
+  if layout == EpochMillis {
+    return strconv.FormatInt(r.Time.UnixMilli(), 10)
+  }
+  return r.Time.Format(layout)
+}
+
+// now returns the current time of the logger clock.
+func (l *Logger) now() time.Time {
+  if l.clock != nil {
+    return l.clock()
+  }
+  return time.Now()
+}
+
+// LogTimeLayout returns a function to set the layout of the timestamps
+// written by the built-in encoders, such as time.RFC3339Nano or
+// EpochMillis.
+func LogTimeLayout(layout string) func(Logger) Logger {
+  return func(l Logger) Logger {
+    l.timeLayout = layout
+    return l
+  }
+}
+
+// LogUTC sets the records time, and the rotation of the log files, to UTC.
+func LogUTC(l Logger) Logger {
+  l.utc = true
+  return l
+}
+
+// LogClock returns a function to read the time from now, for the records
+// and the rotation of the log files.
+func LogClock(now func() time.Time) func(Logger) Logger {
+  return func(l Logger) Logger {
+    l.clock = now
+    return l
+  }
+}
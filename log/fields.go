@@ -0,0 +1,255 @@
+package log
+
+import (
+  "bytes"
+  "fmt"
+  "os"
+  "strconv"
+  "time"
+  "unicode"
+  "unicode/utf8"
+)
+
+// badKey is used for a value passed without a matching string key.
+const badKey = "!BADKEY"
+
+// Field is a key-value pair attached to a log line.
+type Field struct {
+  Key   string
+  Value interface{}
+}
+
+// String returns a string field.
+func String(key, value string) Field {
+  return Field{Key: key, Value: value}
+}
+
+// Int returns an int field.
+func Int(key string, value int) Field {
+  return Field{Key: key, Value: value}
+}
+
+// Int64 returns an int64 field.
+func Int64(key string, value int64) Field {
+  return Field{Key: key, Value: value}
+}
+
+// Uint64 returns an uint64 field.
+func Uint64(key string, value uint64) Field {
+  return Field{Key: key, Value: value}
+}
+
+// Float64 returns a float64 field.
+func Float64(key string, value float64) Field {
+  return Field{Key: key, Value: value}
+}
+
+// Bool returns a bool field.
+func Bool(key string, value bool) Field {
+  return Field{Key: key, Value: value}
+}
+
+// Duration returns a time.Duration field.
+func Duration(key string, value time.Duration) Field {
+  return Field{Key: key, Value: value}
+}
+
+// Time returns a time.Time field.
+func Time(key string, value time.Time) Field {
+  return Field{Key: key, Value: value}
+}
+
+// Err returns an error field with the key "error".
+func Err(err error) Field {
+  return Field{Key: "error", Value: err}
+}
+
+// Group returns a field nesting fields under key.
+func Group(key string, fields ...Field) Field {
+  return Field{Key: key, Value: fields}
+}
+
+// Any returns a field holding an arbitrary value.
+func Any(key string, value interface{}) Field {
+  return Field{Key: key, Value: value}
+}
+
+// fieldsFromKV converts alternating key/value arguments into fields. A Field
+// argument is taken as is, a value without a string key gets badKey.
+func fieldsFromKV(kv []interface{}) []Field {
+  if len(kv) == 0 {
+    return nil
+  }
+  fields := make([]Field, 0, (len(kv)+1)/2)
+  for i := 0; i < len(kv); i++ {
+    switch k := kv[i].(type) {
+    case Field:
+      fields = append(fields, k)
+    case string:
+      if i+1 < len(kv) {
+        fields = append(fields, Field{Key: k, Value: kv[i+1]})
+        i++
+      } else {
+        fields = append(fields, Field{Key: badKey, Value: k})
+      }
+    default:
+      fields = append(fields, Field{Key: badKey, Value: k})
+    }
+  }
+  return fields
+}
+
+// mergeFields returns a new slice holding a followed by b, so that children
+// never share a backing array with their parent.
+func mergeFields(a, b []Field) []Field {
+  if len(b) == 0 {
+    return a
+  }
+  if len(a) == 0 {
+    return b
+  }
+  fields := make([]Field, 0, len(a)+len(b))
+  fields = append(fields, a...)
+  return append(fields, b...)
+}
+
+// appendFields renders fields as " key=value" pairs.
+func appendFields(b *bytes.Buffer, fields []Field) {
+  walkFields("", fields, func(key string, v interface{}) {
+    b.WriteByte(' ')
+    b.WriteString(key)
+    b.WriteByte('=')
+    b.WriteString(quoteIfNeeded(fieldString(v)))
+  })
+}
+
+// walkFields calls fn for every field, flattening a group, a field holding
+// []Field, into "group.key" fields.
+func walkFields(prefix string, fields []Field, fn func(key string, v interface{})) {
+  for _, f := range fields {
+    if group, ok := f.Value.([]Field); ok {
+      walkFields(prefix+f.Key+".", group, fn)
+      continue
+    }
+    fn(prefix+f.Key, f.Value)
+  }
+}
+
+// fieldString formats a field value as text.
+func fieldString(v interface{}) string {
+  switch v := v.(type) {
+  case nil:
+    return "<nil>"
+  case string:
+    return v
+  case error:
+    return v.Error()
+  case time.Time:
+    return v.Format(time.RFC3339Nano)
+  case fmt.Stringer:
+    return v.String()
+  default:
+    return fmt.Sprint(v)
+  }
+}
+
+// quoteIfNeeded quotes s when it is empty or holds spaces, '=', quotes,
+// control or invalid characters, so that a key=value pair stays parseable.
+func quoteIfNeeded(s string) string {
+  if s == "" {
+    return `""`
+  }
+  for _, r := range s {
+    if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError || !unicode.IsPrint(r) {
+      return strconv.Quote(s)
+    }
+  }
+  return s
+}
+
+// With returns a child adaptor that emits the given key-value pairs on
+// every line, after the fields of l. Arguments alternate between string keys
+// and values; Field values may be mixed in.
+func (l *LogAdaptor) With(kv ...interface{}) *LogAdaptor {
+  return l.WithFields(fieldsFromKV(kv)...)
+}
+
+// WithFields returns a child adaptor that emits fields on every line.
+func (l *LogAdaptor) WithFields(fields ...Field) *LogAdaptor {
+  child := *l
+  child.fields = mergeFields(l.fields, fields)
+  return &child
+}
+
+// Tracew prints trace log with key-value pairs.
+func (l *LogAdaptor) Tracew(msg string, kv ...interface{}) {
+  l.current().doPrintwN(l.calldepth, TRACE, mergeFields(l.fields, fieldsFromKV(kv)), msg)
+}
+
+// Debugw prints debug log with key-value pairs.
+func (l *LogAdaptor) Debugw(msg string, kv ...interface{}) {
+  l.current().doPrintwN(l.calldepth, DEBUG, mergeFields(l.fields, fieldsFromKV(kv)), msg)
+}
+
+// Infow prints info log with key-value pairs.
+func (l *LogAdaptor) Infow(msg string, kv ...interface{}) {
+  l.current().doPrintwN(l.calldepth, INFO, mergeFields(l.fields, fieldsFromKV(kv)), msg)
+}
+
+// Warnw prints warn log with key-value pairs.
+func (l *LogAdaptor) Warnw(msg string, kv ...interface{}) {
+  l.current().doPrintwN(l.calldepth, WARN, mergeFields(l.fields, fieldsFromKV(kv)), msg)
+}
+
+// Errorw prints error log with key-value pairs.
+func (l *LogAdaptor) Errorw(msg string, kv ...interface{}) {
+  l.current().doPrintwN(l.calldepth, ERROR, mergeFields(l.fields, fieldsFromKV(kv)), msg)
+}
+
+// Fatalw prints fatal log with key-value pairs and exits.
+func (l *LogAdaptor) Fatalw(msg string, kv ...interface{}) {
+  l.current().doPrintwN(l.calldepth, FATAL, mergeFields(l.fields, fieldsFromKV(kv)), msg)
+  os.Exit(1)
+}
+
+// With returns a child of the default adaptor carrying the key-value pairs.
+func With(kv ...interface{}) *LogAdaptor {
+  return Default().With(kv...)
+}
+
+// WithFields returns a child of the default adaptor carrying fields.
+func WithFields(fields ...Field) *LogAdaptor {
+  return Default().WithFields(fields...)
+}
+
+// Tracew prints trace log with key-value pairs.
+func Tracew(msg string, kv ...interface{}) {
+  pkgAdaptor().Tracew(msg, kv...)
+}
+
+// Debugw prints debug log with key-value pairs.
+func Debugw(msg string, kv ...interface{}) {
+  pkgAdaptor().Debugw(msg, kv...)
+}
+
+// Infow prints info log with key-value pairs.
+func Infow(msg string, kv ...interface{}) {
+  pkgAdaptor().Infow(msg, kv...)
+}
+
+// Warnw prints warn log with key-value pairs.
+func Warnw(msg string, kv ...interface{}) {
+  pkgAdaptor().Warnw(msg, kv...)
+}
+
+// Errorw prints error log with key-value pairs.
+func Errorw(msg string, kv ...interface{}) {
+  pkgAdaptor().Errorw(msg, kv...)
+}
+
+// Fatalw prints fatal log with key-value pairs and exits.
+func Fatalw(msg string, kv ...interface{}) {
+  fatalWithoutDefault(msg, fieldsFromKV(kv))
+  pkgAdaptor().Fatalw(msg, kv...)
+  os.Exit(1)
+}
@@ -0,0 +1,172 @@
+package log
+
+import (
+  "fmt"
+  "os"
+  "runtime/debug"
+  "sync"
+  "sync/atomic"
+  "time"
+)
+
+// hookQueueSize bounds the records waiting for the hooks, the records
+// arriving while it is full skip the hooks.
+const hookQueueSize = 1024
+
+type hook struct {
+  level LogLevel
+  fn    func(Record)
+}
+
+type hookJob struct {
+  r    Record
+  done chan struct{}
+}
+
+// hookSet runs the hooks of a logger from a dedicated goroutine, so that a
+// slow, blocking or logging hook can't stall or deadlock the logger.
+type hookSet struct {
+  mu      sync.Mutex
+  hooks   atomic.Value // []hook
+  queue   chan hookJob
+  dropped uint64
+  done    chan struct{}
+  stopped chan struct{}
+  once    sync.Once
+}
+
+func newHookSet() *hookSet {
+  h := &hookSet{
+    queue:   make(chan hookJob, hookQueueSize),
+    done:    make(chan struct{}),
+    stopped: make(chan struct{}),
+  }
+  h.hooks.Store([]hook(nil))
+  go h.run()
+  return h
+}
+
+func (h *hookSet) add(level LogLevel, fn func(Record)) {
+  h.mu.Lock()
+  defer h.mu.Unlock()
+  old := h.hooks.Load().([]hook)
+  hooks := make([]hook, len(old), len(old)+1)
+  copy(hooks, old)
+  h.hooks.Store(append(hooks, hook{level: level, fn: fn}))
+}
+
+// fire queues r for the hooks interested in its level.
+func (h *hookSet) fire(r *Record) {
+  hooks := h.hooks.Load().([]hook)
+  for _, hk := range hooks {
+    if r.Level >= hk.level {
+      job := hookJob{r: *r}
+      if len(r.Fields) > 0 {
+        job.r.Fields = append([]Field(nil), r.Fields...)
+      }
+      select {
+      case h.queue <- job:
+      default:
+        atomic.AddUint64(&h.dropped, 1)
+      }
+      return
+    }
+  }
+}
+
+func (h *hookSet) run() {
+  defer close(h.stopped)
+  for {
+    select {
+    case job := <-h.queue:
+      h.call(job)
+    case <-h.done:
+      for len(h.queue) > 0 {
+        h.call(<-h.queue)
+      }
+      return
+    }
+  }
+}
+
+func (h *hookSet) call(job hookJob) {
+  if job.done != nil {
+    close(job.done)
+    return
+  }
+  for _, hk := range h.hooks.Load().([]hook) {
+    if job.r.Level >= hk.level {
+      h.safeCall(hk.fn, job.r)
+    }
+  }
+}
+
+// safeCall contains and reports a panicking hook.
+func (h *hookSet) safeCall(fn func(Record), r Record) {
+  defer func() {
+    if err := recover(); err != nil {
+      fmt.Fprintf(os.Stderr, "log: hook panic: %v\n%s", err, debug.Stack())
+    }
+  }()
+  fn(r)
+}
+
+// flush waits until the queued records went through the hooks.
+func (h *hookSet) flush(timeout time.Duration) bool {
+  timer := time.NewTimer(timeout)
+  defer timer.Stop()
+  done := make(chan struct{})
+  select {
+  case h.queue <- hookJob{done: done}:
+  case <-h.stopped:
+    return true
+  case <-timer.C:
+    return false
+  }
+  select {
+  case <-done:
+    return true
+  case <-h.stopped:
+    return true
+  case <-timer.C:
+    return false
+  }
+}
+
+func (h *hookSet) close(timeout time.Duration) {
+  h.flush(timeout)
+  h.once.Do(func() {
+    close(h.done)
+  })
+}
+
+// LogHook returns a function to register fn, called with every record at or
+// above minLevel.
+func LogHook(minLevel LogLevel, fn func(Record)) func(Logger) Logger {
+  return func(l Logger) Logger {
+    hooks := make([]hook, len(l.initHooks), len(l.initHooks)+1)
+    copy(hooks, l.initHooks)
+    l.initHooks = append(hooks, hook{level: minLevel, fn: fn})
+    return l
+  }
+}
+
+// AddHook registers fn, called with every record at or above minLevel.
+// Hooks run one at a time on a goroutine of their own: they don't hold up
+// the logging call, may log themselves, and a panic is recovered and
+// reported to stderr. Records are skipped when the hooks fall far behind.
+func (l *Logger) AddHook(minLevel LogLevel, fn func(Record)) {
+  if l.state != nil {
+    l.state.hooks.add(minLevel, fn)
+  }
+}
+
+// AddHook registers fn, called with every record at or above minLevel.
+func (l *LogAdaptor) AddHook(minLevel LogLevel, fn func(Record)) {
+  l.current().AddHook(minLevel, fn)
+}
+
+// AddHook registers fn on the default logger.
+func AddHook(minLevel LogLevel, fn func(Record)) {
+  pkgAdaptor().AddHook(minLevel, fn)
+}
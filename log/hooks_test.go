@@ -0,0 +1,50 @@
+package log
+
+import (
+  "sync"
+  "testing"
+  "time"
+)
+
+func TestHooks(t *testing.T) {
+  inst := NewLogInstance(LogFilePath(t.TempDir(), "hooks.log"), LogFlags(Lfunc))
+  defer inst.Stop()
+  l := NewAdaptorFromInstance(inst, 3)
+
+  var mu sync.Mutex
+  var got []Record
+  l.AddHook(WARN, func(r Record) {
+    mu.Lock()
+    got = append(got, r)
+    mu.Unlock()
+  })
+  l.AddHook(ERROR, func(r Record) {
+    panic("bad hook")
+  })
+  l.AddHook(ERROR, func(r Record) {
+    // logging from a hook must not deadlock
+    l.Infof("hook saw %s", r.Message)
+  })
+
+  l.Infof("skipped")
+  l.With("k", 1).Warnf("warned")
+  l.Errorf("failed")
+  if !inst.state.hooks.flush(time.Second) {
+    t.Fatal("hooks not flushed")
+  }
+
+  mu.Lock()
+  defer mu.Unlock()
+  if len(got) != 2 {
+    t.Fatalf("got %d records, want 2", len(got))
+  }
+  if got[0].Message != "warned" || got[0].Level != WARN || len(got[0].Fields) != 1 {
+    t.Errorf("unexpected record %+v", got[0])
+  }
+  if got[0].Func == "" {
+    t.Errorf("caller missing in %+v", got[0])
+  }
+  if got[1].Message != "failed" || got[1].Level != ERROR {
+    t.Errorf("unexpected record %+v", got[1])
+  }
+}
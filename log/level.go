@@ -0,0 +1,148 @@
+package log
+
+import (
+  "encoding/json"
+  "errors"
+  "fmt"
+  "mime"
+  "net/http"
+  "sync"
+  "time"
+)
+
+// LevelHandler is an http.Handler reporting the level of a logger on GET
+// and changing it on PUT or POST.
+//
+// The new level is given as a JSON body, {"level":"debug","ttl":"10m"}, or
+// as form values. With a ttl the previous level comes back once it elapses.
+type LevelHandler struct {
+  logger *Logger
+  mu     sync.Mutex
+  timer  *time.Timer
+  prev   LogLevel  // restored by the timer
+  until  time.Time // when the timer fires
+  gen    int
+}
+
+type levelState struct {
+  Level    string `json:"level"`
+  Previous string `json:"previous,omitempty"`
+  Until    string `json:"until,omitempty"`
+}
+
+type levelRequest struct {
+  Level string `json:"level"`
+  TTL   string `json:"ttl"`
+}
+
+// NewLevelHandler returns a handler controlling the level of l.
+func NewLevelHandler(l *Logger) *LevelHandler {
+  return &LevelHandler{logger: l}
+}
+
+// LevelHandler returns a handler controlling the level of the logger.
+func (l *LogAdaptor) LevelHandler() *LevelHandler {
+  return NewLevelHandler(l.current())
+}
+
+// ServeHTTP implements http.Handler.
+func (h *LevelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+  switch r.Method {
+  case http.MethodGet, http.MethodHead:
+  case http.MethodPut, http.MethodPost:
+    level, ttl, err := parseLevelRequest(r)
+    if err != nil {
+      writeLevelJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+      return
+    }
+    h.set(level, ttl)
+  default:
+    w.Header().Set("Allow", "GET, HEAD, PUT, POST")
+    writeLevelJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+    return
+  }
+  writeLevelJSON(w, http.StatusOK, h.state())
+}
+
+// set changes the level, for ttl if positive. Successive temporary changes
+// all revert to the level preceding the first one.
+func (h *LevelHandler) set(level LogLevel, ttl time.Duration) {
+  h.mu.Lock()
+  defer h.mu.Unlock()
+  pending := h.timer != nil
+  if pending {
+    h.timer.Stop()
+    h.timer = nil
+  }
+  h.gen++
+  if ttl > 0 {
+    if !pending {
+      h.prev = h.logger.Level()
+    }
+    gen := h.gen
+    h.until = time.Now().Add(ttl)
+    h.timer = time.AfterFunc(ttl, func() {
+      h.revert(gen)
+    })
+  }
+  SetLevel(h.logger, level)
+}
+
+func (h *LevelHandler) revert(gen int) {
+  h.mu.Lock()
+  defer h.mu.Unlock()
+  if gen != h.gen {
+    return
+  }
+  h.timer = nil
+  SetLevel(h.logger, h.prev)
+}
+
+func (h *LevelHandler) state() levelState {
+  h.mu.Lock()
+  defer h.mu.Unlock()
+  s := levelState{Level: h.logger.Level().String()}
+  if h.timer != nil {
+    s.Previous = h.prev.String()
+    s.Until = h.until.Format(time.RFC3339)
+  }
+  return s
+}
+
+func parseLevelRequest(r *http.Request) (LogLevel, time.Duration, error) {
+  var req levelRequest
+  ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+  if ct == "application/json" {
+    if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 1<<16)).Decode(&req); err != nil {
+      return 0, 0, err
+    }
+  } else {
+    if err := r.ParseForm(); err != nil {
+      return 0, 0, err
+    }
+    req.Level, req.TTL = r.Form.Get("level"), r.Form.Get("ttl")
+  }
+  if req.Level == "" {
+    return 0, 0, errors.New("log: missing level")
+  }
+  level, err := ParseLevel(req.Level)
+  if err != nil {
+    return 0, 0, err
+  }
+  var ttl time.Duration
+  if req.TTL != "" {
+    if ttl, err = time.ParseDuration(req.TTL); err != nil {
+      return 0, 0, err
+    }
+    if ttl <= 0 {
+      return 0, 0, fmt.Errorf("log: ttl %s must be positive", req.TTL)
+    }
+  }
+  return level, ttl, nil
+}
+
+func writeLevelJSON(w http.ResponseWriter, code int, v interface{}) {
+  w.Header().Set("Content-Type", "application/json")
+  w.WriteHeader(code)
+  json.NewEncoder(w).Encode(v)
+}
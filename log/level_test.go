@@ -0,0 +1,94 @@
+package log
+
+import (
+  "encoding/json"
+  "net/http"
+  "net/http/httptest"
+  "net/url"
+  "strings"
+  "sync"
+  "testing"
+  "time"
+)
+
+func TestLevelHandler(t *testing.T) {
+  inst := NewLogInstance(LogFilePath(t.TempDir(), "level.log"), InfoLevel)
+  defer inst.Stop()
+  l := NewAdaptorFromInstance(inst, 3)
+  srv := httptest.NewServer(l.LevelHandler())
+  defer srv.Close()
+
+  get := func(resp *http.Response, err error) levelState {
+    t.Helper()
+    if err != nil {
+      t.Fatal(err)
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+      t.Fatalf("status %s", resp.Status)
+    }
+    var s levelState
+    if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+      t.Fatal(err)
+    }
+    return s
+  }
+
+  // log concurrently with the changes
+  done := make(chan struct{})
+  var wg sync.WaitGroup
+  wg.Add(1)
+  go func() {
+    defer wg.Done()
+    for {
+      select {
+      case <-done:
+        return
+      default:
+        l.Debugf("busy")
+      }
+    }
+  }()
+  defer func() {
+    close(done)
+    wg.Wait()
+  }()
+
+  if s := get(http.Get(srv.URL)); s.Level != "INFO" {
+    t.Errorf("got %+v", s)
+  }
+  req, _ := http.NewRequest(http.MethodPut, srv.URL, strings.NewReader(`{"level":"warn"}`))
+  req.Header.Set("Content-Type", "application/json")
+  if s := get(http.DefaultClient.Do(req)); s.Level != "WARN" || s.Until != "" {
+    t.Errorf("got %+v", s)
+  }
+  s := get(http.PostForm(srv.URL, url.Values{"level": {"debug"}, "ttl": {"100ms"}}))
+  if s.Level != "DEBUG" || s.Previous != "WARN" || s.Until == "" {
+    t.Errorf("got %+v", s)
+  }
+  if l.Level() != DEBUG {
+    t.Errorf("level %v", l.Level())
+  }
+  // a second temporary change keeps the original level to revert to
+  if s := get(http.PostForm(srv.URL, url.Values{"level": {"TRC"}, "ttl": {"100ms"}})); s.Previous != "WARN" {
+    t.Errorf("got %+v", s)
+  }
+  time.Sleep(300 * time.Millisecond)
+  if s := get(http.Get(srv.URL)); s.Level != "WARN" || s.Previous != "" {
+    t.Errorf("not reverted: %+v", s)
+  }
+
+  for _, form := range []url.Values{{"level": {"loud"}}, {"level": {"debug"}, "ttl": {"-5m"}}, {"level": {"debug"}, "ttl": {"0s"}}} {
+    resp, err := http.PostForm(srv.URL, form)
+    if err != nil {
+      t.Fatal(err)
+    }
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusBadRequest {
+      t.Errorf("%v: status %s", form, resp.Status)
+    }
+  }
+  if l.Level() != WARN {
+    t.Errorf("level changed to %v", l.Level())
+  }
+}
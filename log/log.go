@@ -4,7 +4,6 @@ import (
   "fmt"
   "log"
   "os"
-  "path"
   "runtime"
   "strings"
   "sync/atomic"
@@ -35,10 +34,9 @@ const (
 )
 
 var (
-  started        int32
-  loggerInstance *LogAdaptor
-  logger         Logger
-  tagName        = map[LogLevel]string{
+  started int32
+  std     atomic.Pointer[defaultAdaptors]
+  tagName = map[LogLevel]string{
     TRACE: "TRC",
     DEBUG: "DBG",
     INFO:  "INF",
@@ -48,168 +46,173 @@ var (
   }
 )
 
-func NewLogInstance(decorators ...func(Logger) Logger) Logger {
-  inst := Logger{}
+// NewLogInstance returns a logger configured by the decorators. The
+// returned pointer is the handle of the logger: copies of the Logger value
+// share its level, sinks and stopped state.
+func NewLogInstance(decorators ...func(Logger) Logger) *Logger {
+  inst := &Logger{}
   for _, decorator := range decorators {
-    inst = decorator(inst)
+    *inst = decorator(*inst)
   }
-  var logger *log.Logger
+  st := &loggerState{}
+  inst.state = st
+  st.level.Store(int32(inst.level))
+  st.flags.Store(inst.flags)
+  st.rules.Store(newRules(inst.ruleSpec))
   var segment *logSegment
   if inst.logPath != "" {
-    segment = newLogSegment(inst.unit, inst.logPath, inst.name)
+    segment = newLogSegment(*inst)
   }
+  sinks := make([]sinkEntry, 0, len(inst.sinks)+2)
   if segment != nil {
-    logger = log.New(segment, "", log.LstdFlags|log.Lmicroseconds)
-    inst.segment = segment
-  } else {
-    logger = log.New(os.Stderr, "", log.LstdFlags|log.Lmicroseconds)
+    sinks = append(sinks, sinkEntry{sink: NewWriterSink(segment, inst.encoder)})
+  } else if !inst.noStderr {
+    sinks = append(sinks, sinkEntry{sink: NewWriterSink(os.Stderr, inst.consoleEncoder(os.Stderr))})
+  }
+  if inst.isStdout {
+    // same destination as the standard logger, which we must not close
+    w := log.Writer()
+    sinks = append(sinks, sinkEntry{sink: NewWriterSink(noCloseWriter{w}, inst.consoleEncoder(w))})
+  }
+  sinks = append(sinks, inst.sinks...)
+  st.sinks.Store(&sinks)
+  if inst.asyncSize > 0 {
+    st.async = newAsyncQueue(inst.asyncSize, inst.overflow, &st.sinks)
+  }
+  if len(inst.reopenSignals) > 0 {
+    st.reopener = watchSignals(inst, inst.reopenSignals)
+  }
+  st.hooks = newHookSet()
+  for _, hk := range inst.initHooks {
+    st.hooks.add(hk.level, hk.fn)
+  }
+  if inst.sampling.interval > 0 {
+    st.sampler = newSampler(inst.sampling, inst)
   }
-  inst.logger = logger
   return inst
 }
 
-// Start returns a decorated innerLogger.
+// New returns an adaptor on a new logger configured by the decorators,
+// independent of the default logger and of any other instance.
+func New(decorators ...func(Logger) Logger) *LogAdaptor {
+  return NewAdaptorFromInstance(NewLogInstance(decorators...), 3)
+}
+
+// Start returns a decorated innerLogger, set as the default logger.
 func Start(decorators ...func(Logger) Logger) *LogAdaptor {
   if atomic.CompareAndSwapInt32(&started, 0, 1) {
-    logger = NewLogInstance(decorators...)
-    loggerInstance = NewAdaptorFromInstance(&logger, 4)
-    return loggerInstance
+    l := New(decorators...)
+    SetDefault(l)
+    return l
   }
   //return nil
   panic("Start() already called")
 }
 
-func (l Logger) Release() {
-  if l.printStack {
-    traceInfo := make([]byte, 1<<16)
-    n := runtime.Stack(traceInfo, true)
-    l.logger.Printf("%s", traceInfo[:n])
-    if l.isStdout {
-      log.Printf("%s", traceInfo[:n])
+// defaultAdaptors holds the default adaptor and its copy used by the
+// package-level functions, one frame deeper.
+type defaultAdaptors struct {
+  adaptor *LogAdaptor
+  pkg     *LogAdaptor
+}
+
+// SetDefault makes l the logger of the package-level functions, or turns
+// them into no-ops when l is nil. The previous default is left running. An
+// adaptor following the default logger is resolved to the logger it
+// currently uses.
+func SetDefault(l *LogAdaptor) {
+  if l != nil && l.std != nil {
+    if l.std.Load() == nil {
+      l = nil
+    } else {
+      resolved := *l
+      resolved.logger = l.current()
+      resolved.std, resolved.name = nil, ""
+      l = &resolved
     }
   }
-  if l.segment != nil {
-    l.segment.Close()
+  if l == nil {
+    std.Store(nil)
+    return
   }
-  l.segment = nil
-  l.logger = nil
+  pkg := *l
+  pkg.calldepth++
+  std.Store(&defaultAdaptors{adaptor: l, pkg: &pkg})
 }
 
-func Stop() {
-  logger.Stop()
-}
-
-// Stop stops the logger.
-func (l Logger) Stop() {
-  if atomic.CompareAndSwapInt32(&l.stopped, 0, 1) {
-    l.Release()
-    atomic.StoreInt32(&started, 0)
+// Default returns the default adaptor. When there is none yet, it returns
+// an adaptor following the default logger once set.
+func Default() *LogAdaptor {
+  if d := std.Load(); d != nil {
+    return d.adaptor
   }
+  return NewAdaptor(3)
 }
 
-// logSegment implements io.Writer
-type logSegment struct {
-  unit         time.Duration
-  logPath      string
-  logFileName  string
-  logFile      *os.File
-  pid          int
-  timeToCreate <-chan time.Time
+// pkgAdaptor returns the adaptor of the package-level functions.
+func pkgAdaptor() *LogAdaptor {
+  if d := std.Load(); d != nil {
+    return d.pkg
+  }
+  return NewAdaptorFromInstance(&Logger{}, 4)
 }
 
-func newLogSegment(unit time.Duration, logPath string, fileName string) *logSegment {
-  now := time.Now()
-  if logPath != "" {
-    err := os.MkdirAll(logPath, os.ModePerm)
-    if err != nil {
-      fmt.Fprintln(os.Stderr, err)
-      return nil
-    }
-    name := strings.TrimSpace(fileName)
-    if name == "" {
-      name = getLogName()
-    }
-    filename := path.Join(logPath, name)
-    logFile, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
-    if err != nil {
-      if os.IsNotExist(err) {
-        logFile, err = os.Create(path.Join(logPath, name))
-        if err != nil {
-          fmt.Fprintln(os.Stderr, err)
-          return nil
-        }
-      } else {
-        fmt.Fprintln(os.Stderr, err)
-        return nil
-      }
-    }
-    next := now.Truncate(unit).Add(unit)
-    var timeToCreate <-chan time.Time
-    if unit == time.Hour || unit == time.Minute {
-      timeToCreate = time.After(next.Sub(time.Now()))
-    }
-    return &logSegment{
-      unit:         unit,
-      logPath:      logPath,
-      logFileName:  filename,
-      logFile:      logFile,
-      pid:          os.Getpid(),
-      timeToCreate: timeToCreate,
-    }
+// Release flushes and closes the sinks of the logger. Records logged
+// afterwards are discarded. It is safe to call more than once, from any
+// copy of the logger.
+func (l *Logger) Release() {
+  if l.state != nil && l.state.stopped.CompareAndSwap(false, true) {
+    l.release()
   }
-  return nil
 }
 
-func (ls *logSegment) Write(p []byte) (n int, err error) {
-  if ls.timeToCreate != nil && ls.logFile != os.Stdout && ls.logFile != os.Stderr {
-    select {
-    case current := <-ls.timeToCreate:
-      backup := getLogFileName(current)
-      val := path.Join(ls.logPath, backup)
-      ls.logFile.Close()
-      ls.logFile = nil
-      os.Rename(ls.logFileName, val)
-      
-      ls.logFile, err = os.Create(ls.logFileName)
-      if err != nil {
-        // log into stderr if we can't create new file
-        fmt.Fprintln(os.Stderr, err)
-        ls.logFile = os.Stderr
-      } else {
-        next := current.Truncate(ls.unit).Add(ls.unit)
-        ls.timeToCreate = time.After(next.Sub(time.Now()))
-      }
-    default:
-      // do nothing
+func (l *Logger) release() {
+  st := l.state
+  if l.printStack {
+    traceInfo := make([]byte, 1<<16)
+    n := runtime.Stack(traceInfo, true)
+    l.dispatch(&Record{Time: l.now(), Level: INFO, Message: string(traceInfo[:n])})
+  }
+  if st.reopener != nil {
+    st.reopener.stop()
+  }
+  if st.sampler != nil {
+    st.sampler.stop()
+  }
+  st.hooks.close(stopFlushTimeout)
+  if st.async != nil {
+    st.async.close(stopFlushTimeout)
+  }
+  if sinks := st.sinks.Swap(nil); sinks != nil {
+    for _, s := range *sinks {
+      s.sink.Close()
     }
   }
-  return ls.logFile.Write(p)
-}
-
-func (ls *logSegment) Close() {
-  ls.logFile.Close()
 }
 
-func getLogName() string {
-  return path.Base(os.Args[0]) + ".log"
+// Stop stops the default logger.
+func Stop() {
+  pkgAdaptor().Stop()
 }
 
-func getLogFileName(t time.Time) string {
-  proc := path.Base(os.Args[0])
-  now := time.Now()
-  year := now.Year()
-  month := now.Month()
-  day := now.Day()
-  hour := now.Hour()
-  minute := now.Minute()
-  pid := os.Getpid()
-  return fmt.Sprintf("%s.%04d-%02d-%02d-%02d-%02d.%d.log",
-    proc, year, month, day, hour, minute, pid)
+// Stop stops the logger. Only the first call, from any copy, has an effect.
+// Stopping the default logger turns the package-level functions into
+// no-ops and lets Start be called again.
+func (l *Logger) Stop() {
+  if l.state != nil && l.state.stopped.CompareAndSwap(false, true) {
+    l.release()
+    if d := std.Load(); d != nil && d.adaptor.current().state == l.state && std.CompareAndSwap(d, nil) {
+      atomic.StoreInt32(&started, 0)
+    }
+  }
 }
 
 type LogAdaptor struct {
   logger    *Logger
+  std       *atomic.Pointer[defaultAdaptors] // follows the default logger when set
+  name      string                           // logger name when following the default
   calldepth int
+  fields    []Field
 }
 
 func NewAdaptorFromInstance(log *Logger, callDepth int) *LogAdaptor {
@@ -219,85 +222,109 @@ func NewAdaptorFromInstance(log *Logger, callDepth int) *LogAdaptor {
   }
 }
 
+// NewAdaptor returns an adaptor on the default logger, whichever it is when
+// logging: it may be created before Start or SetDefault.
 func NewAdaptor(callDepth int) *LogAdaptor {
   return &LogAdaptor{
-    logger:    &logger,
+    std:       &std,
     calldepth: callDepth,
   }
 }
 
+// current returns the logger of l, a no-op one when l follows the default
+// logger and there is none.
+func (l *LogAdaptor) current() *Logger {
+  if l.std == nil {
+    return l.logger
+  }
+  d := l.std.Load()
+  if d == nil {
+    return &Logger{loggerName: l.name}
+  }
+  if l.name == "" {
+    return d.adaptor.current()
+  }
+  logger := *d.adaptor.current()
+  logger.loggerName = l.name
+  return &logger
+}
+
 func (l *LogAdaptor) Print(v ...interface{}) {
-  l.logger.Print(v...)
+  l.current().Print(v...)
 }
 
 func (l *LogAdaptor) Printf(format string, v ...interface{}) {
-  l.logger.Printf(format, v...)
+  l.current().Printf(format, v...)
 }
 
 func (l *LogAdaptor) Tracef(format string, v ...interface{}) {
-  l.logger.doPrintfN(l.calldepth, TRACE, format, v...)
+  l.current().doPrintfN(l.calldepth, TRACE, l.fields, format, v...)
 }
 
 // Debugf prints formatted debug log.
 func (l *LogAdaptor) Debugf(format string, v ...interface{}) {
-  l.logger.doPrintfN(l.calldepth, DEBUG, format, v...)
+  l.current().doPrintfN(l.calldepth, DEBUG, l.fields, format, v...)
 }
 
 // Infof prints formatted info log.
 func (l *LogAdaptor) Infof(format string, v ...interface{}) {
-  l.logger.doPrintfN(l.calldepth, INFO, format, v...)
+  l.current().doPrintfN(l.calldepth, INFO, l.fields, format, v...)
 }
 
 // Warnf prints formatted warn log.
 func (l *LogAdaptor) Warnf(format string, v ...interface{}) {
-  l.logger.doPrintfN(l.calldepth, WARN, format, v...)
+  l.current().doPrintfN(l.calldepth, WARN, l.fields, format, v...)
 }
 
 // Errorf prints formatted error log.
 func (l *LogAdaptor) Errorf(format string, v ...interface{}) {
-  l.logger.doPrintfN(l.calldepth, ERROR, format, v...)
+  l.current().doPrintfN(l.calldepth, ERROR, l.fields, format, v...)
 }
 
 // Fatalf prints formatted fatal log and exits.
 func (l *LogAdaptor) Fatalf(format string, v ...interface{}) {
-  l.logger.doPrintfN(l.calldepth, FATAL, format, v...)
+  l.current().doPrintfN(l.calldepth, FATAL, l.fields, format, v...)
   os.Exit(1)
 }
 
 // Traceln prints debug log.
 func (l *LogAdaptor) Traceln(v ...interface{}) {
-  l.logger.doPrintlnN(l.calldepth, TRACE, v...)
+  l.current().doPrintlnN(l.calldepth, TRACE, l.fields, v...)
 }
 
 // Debugln prints debug log.
 func (l *LogAdaptor) Debugln(v ...interface{}) {
-  l.logger.doPrintlnN(l.calldepth, DEBUG, v...)
+  l.current().doPrintlnN(l.calldepth, DEBUG, l.fields, v...)
 }
 
 // Infoln prints info log.
 func (l *LogAdaptor) Infoln(v ...interface{}) {
-  l.logger.doPrintlnN(l.calldepth, INFO, v...)
+  l.current().doPrintlnN(l.calldepth, INFO, l.fields, v...)
 }
 
 // Warnln prints warn log.
 func (l *LogAdaptor) Warnln(v ...interface{}) {
-  l.logger.doPrintlnN(l.calldepth, WARN, v...)
+  l.current().doPrintlnN(l.calldepth, WARN, l.fields, v...)
 }
 
 // Errorln prints error log.
 func (l *LogAdaptor) Errorln(v ...interface{}) {
-  l.logger.doPrintlnN(l.calldepth, ERROR, v...)
+  l.current().doPrintlnN(l.calldepth, ERROR, l.fields, v...)
 }
 
 // Fatalln prints fatal log and exits.
 func (l *LogAdaptor) Fatalln(v ...interface{}) {
-  l.logger.doPrintlnN(l.calldepth, FATAL, v...)
+  l.current().doPrintlnN(l.calldepth, FATAL, l.fields, v...)
   os.Exit(1)
 }
 
 func (l *LogAdaptor) Write(p []byte) (n int, err error) {
-  l.logger.WriteN(l.calldepth, p)
-  return
+  return l.current().WriteN(l.calldepth, p)
+}
+
+// Stop stops the underlying logger.
+func (l *LogAdaptor) Stop() {
+  l.current().Stop()
 }
 
 func (l *LogAdaptor) SetCallDepth(callDepth int) {
@@ -305,90 +332,127 @@ func (l *LogAdaptor) SetCallDepth(callDepth int) {
 }
 
 func (l *LogAdaptor) SetLevel(level LogLevel) {
-  SetLevel(l.logger, level)
+  SetLevel(l.current(), level)
 }
 
-// Logger is the logger type.
+// Level returns the current level of the logger.
+func (l *LogAdaptor) Level() LogLevel {
+  return l.current().Level()
+}
+
+// Logger is the logger type. The decorators configure a Logger value, a
+// *Logger returned by NewLogInstance is the handle used to log.
 type Logger struct {
-  logger     *log.Logger
-  level      LogLevel
-  segment    *logSegment
-  stopped    int32
-  logPath    string
-  name       string
-  flags      int32
-  unit       time.Duration
-  isStdout   bool
-  printStack bool
-}
-
-func (l Logger) Write(p []byte) (n int, err error) {
+  state         *loggerState
+  sinks         []sinkEntry
+  level         LogLevel
+  clock         func() time.Time
+  timeLayout    string
+  utc           bool
+  loggerName    string
+  ruleSpec      string
+  logPath       string
+  name          string
+  flags         int32
+  encoder       Encoder
+  unit          time.Duration
+  rotation      RotationPolicy
+  maxSize       int64
+  retention     retention
+  compressor    Compressor
+  reopenSignals []os.Signal
+  sampling      samplingConfig
+  initHooks     []hook
+  extractors    []ContextExtractor
+  isStdout      bool
+  noStderr      bool
+  printStack    bool
+  asyncSize     int
+  overflow      OverflowPolicy
+}
+
+// loggerState is the runtime state of a logger, shared by its copies.
+type loggerState struct {
+  level    atomic.Int32
+  flags    atomic.Int32
+  stopped  atomic.Bool
+  sinks    atomic.Pointer[[]sinkEntry] // nil once released
+  rules    atomic.Pointer[levelRules]
+  async    *asyncQueue
+  reopener *signalWatcher
+  sampler  *sampler
+  hooks    *hookSet
+}
+
+// loadSinks returns the sinks of the logger, nil when it is not running.
+func (l *Logger) loadSinks() []sinkEntry {
+  if l.state == nil {
+    return nil
+  }
+  if sinks := l.state.sinks.Load(); sinks != nil {
+    return *sinks
+  }
+  return nil
+}
+
+// running reports whether the logger was started and not released.
+func (l *Logger) running() bool {
+  return l.state != nil && l.state.sinks.Load() != nil
+}
+
+func (l *Logger) Write(p []byte) (n int, err error) {
   l.doPrintln(TRACE, string(p))
-  return
+  return len(p), nil
 }
 
-func (l Logger) WriteN(callDepth int, p []byte) (n int, err error) {
-  l.doPrintlnN(callDepth, TRACE, string(p))
-  return
+func (l *Logger) WriteN(callDepth int, p []byte) (n int, err error) {
+  l.doPrintlnN(callDepth, TRACE, nil, string(p))
+  return len(p), nil
 }
 
-func (l Logger) Print(v ...interface{}) {
+func (l *Logger) Print(v ...interface{}) {
   l.doPrintln(DEBUG, v...)
 }
 
-func (l Logger) PrintN(callDepth int, v ...interface{}) {
-  l.doPrintlnN(callDepth, DEBUG, v...)
+func (l *Logger) PrintN(callDepth int, v ...interface{}) {
+  l.doPrintlnN(callDepth, DEBUG, nil, v...)
 }
 
-func (l Logger) Printf(format string, v ...interface{}) {
-  if l.logger == nil {
-    return
-  }
-  l.logger.Printf(format, v...)
+// Printf writes an untagged line, whatever the level, like the standard
+// logger.
+func (l *Logger) Printf(format string, v ...interface{}) {
+  l.printRaw(fmt.Sprintf(format, v...))
+}
+
+func (l *Logger) PrintfN(callDepth int, format string, v ...interface{}) {
+  l.printRaw(fmt.Sprintf(format, v...))
 }
 
-func (l Logger) PrintfN(callDepth int, format string, v ...interface{}) {
-  if l.logger == nil {
+// printRaw hands msg to every sink without level nor caller.
+func (l *Logger) printRaw(msg string) {
+  if !l.running() {
     return
   }
-  l.logger.Output(callDepth, fmt.Sprintf(format, v...))
+  l.dispatch(&Record{Time: l.now(), Level: INFO, Message: strings.TrimSuffix(msg, "\n"), raw: true})
 }
 
-func (l Logger) doPrintfN(callDepth int, level LogLevel, format string, v ...interface{}) {
-  if l.logger == nil {
+func (l *Logger) doPrintfN(callDepth int, level LogLevel, fields []Field, format string, v ...interface{}) {
+  if !l.running() {
     return
   }
-  if level >= l.level {
-    if l.flags > 0 {
-      funcName, fileName, lineNum := getRuntimeInfo(callDepth)
-      if l.flags&(Lfile|Lline) != 0 {
-        if l.flags&Lline == 0 {
-          format = fmt.Sprintf("%3s [%s] (%s): %s", tagName[level], path.Base(funcName),
-            path.Base(fileName), format)
-        } else {
-          format = fmt.Sprintf("%3s [%s] (%s:%d): %s", tagName[level], path.Base(funcName),
-            path.Base(fileName), lineNum, format)
-        }
-      } else {
-        format = fmt.Sprintf("%3s [%s]: %s", tagName[level], path.Base(funcName), format)
-      }
-      
-    } else {
-      format = fmt.Sprintf("%3s: %s", tagName[level], format)
-    }
-    
-    l.logger.Printf(format, v...)
-    if l.isStdout {
-      log.Printf(format, v...)
+  if l.enabled(callDepth, level) {
+    if l.state.sampler != nil && !l.state.sampler.allow(callDepth, level, format) {
+      return
     }
+    l.output(callDepth+1, level, fields, fmt.Sprintf(format, v...))
     if level == FATAL {
-      os.Exit(1)
+      l.exit()
     }
   }
 }
 
-func (l Logger) doPrintf(level LogLevel, format string, v ...interface{}) {
-  l.doPrintfN(3, level, format, v...)
+func (l *Logger) doPrintf(level LogLevel, format string, v ...interface{}) {
+  l.doPrintfN(3, level, nil, format, v...)
   //if l.logger == nil {
   //  return
   //}
@@ -421,43 +485,116 @@ func (l Logger) doPrintf(level LogLevel, format string, v ...interface{}) {
   //}
 }
 
-func (l Logger) doPrintlnN(callDepth int, level LogLevel, v ...interface{}) {
-  if l.logger == nil {
+func (l *Logger) doPrintlnN(callDepth int, level LogLevel, fields []Field, v ...interface{}) {
+  if !l.running() {
     return
   }
-  if level >= l.level {
-    var prefix string
-    if l.flags > 0 {
-      funcName, fileName, lineNum := getRuntimeInfo(callDepth)
-      if l.flags&(Lfile|Lline) != 0 {
-        if l.flags&Lline != 0 {
-          prefix = fmt.Sprintf("%3s [%s] (%s:%d): ", tagName[level], path.Base(funcName),
-            path.Base(fileName), lineNum)
-        } else {
-          prefix = fmt.Sprintf("%3s [%s] (%s): ", tagName[level], path.Base(funcName),
-            path.Base(fileName))
-        }
-      } else {
-        prefix = fmt.Sprintf("%3s [%s]: ", tagName[level], path.Base(funcName))
-      }
-      
-    } else {
-      prefix = fmt.Sprintf("%3s: ", tagName[level])
+  if l.enabled(callDepth, level) {
+    msg := fmt.Sprintln(v...)
+    if l.state.sampler != nil && !l.state.sampler.allow(callDepth, level, msg) {
+      return
     }
-    
-    value := fmt.Sprintf("%s%s", prefix, fmt.Sprintln(v...))
-    l.logger.Print(value)
-    if l.isStdout {
-      log.Print(value)
+    l.output(callDepth+1, level, fields, msg)
+    if level == FATAL {
+      l.exit()
+    }
+  }
+}
+
+func (l *Logger) doPrintwN(callDepth int, level LogLevel, fields []Field, msg string) {
+  if !l.running() {
+    return
+  }
+  if l.enabled(callDepth, level) {
+    if l.state.sampler != nil && !l.state.sampler.allow(callDepth, level, msg) {
+      return
     }
+    l.output(callDepth+1, level, fields, msg)
     if level == FATAL {
-      os.Exit(1)
+      l.exit()
     }
   }
 }
 
-func (l Logger) doPrintln(level LogLevel, v ...interface{}) {
-  l.doPrintlnN(3, level, v...)
+// output builds a single record and writes it to the sinks.
+func (l *Logger) output(callDepth int, level LogLevel, fields []Field, msg string) {
+  r := Record{
+    Time:    l.now(),
+    Level:   level,
+    Message: strings.TrimSuffix(msg, "\n"),
+    Fields:  fields,
+  }
+  if l.callerFlags() > 0 {
+    funcName, fileName, lineNum := getRuntimeInfo(callDepth)
+    l.setCaller(&r, funcName, fileName, lineNum)
+  }
+
+  l.dispatch(&r)
+}
+
+// setCaller fills the caller information of r allowed by the flags.
+func (l *Logger) setCaller(r *Record, funcName, fileName string, lineNum int) {
+  flags := l.callerFlags()
+  r.Func = funcName
+  if flags&(Lfile|Lline) != 0 {
+    r.File = fileName
+    if flags&Lline != 0 {
+      r.Line = lineNum
+    }
+  }
+}
+
+// callerFlags returns the current flags of the logger.
+func (l *Logger) callerFlags() int32 {
+  if l.state == nil {
+    return l.flags
+  }
+  return l.state.flags.Load()
+}
+
+// dispatch hands r to every sink accepting its level, or queues it when
+// the logger is asynchronous.
+func (l *Logger) dispatch(r *Record) {
+  if l.utc {
+    r.Time = r.Time.UTC()
+  }
+  r.timeLayout = l.timeLayout
+  st := l.state
+  if st == nil {
+    return
+  }
+  st.hooks.fire(r)
+  if st.async != nil {
+    st.async.push(r)
+    return
+  }
+  writeSinks(l.loadSinks(), r)
+}
+
+func writeSinks(sinks []sinkEntry, r *Record) {
+  for _, s := range sinks {
+    if r.Level >= s.level || r.raw {
+      s.sink.WriteRecord(r)
+    }
+  }
+}
+
+// exit flushes queued records and terminates the program after a FATAL log.
+func (l *Logger) exit() {
+  l.flushAll()
+  os.Exit(1)
+}
+
+// flushAll waits for the records queued for the hooks and the sinks.
+func (l *Logger) flushAll() {
+  if l.state != nil {
+    l.state.hooks.flush(stopFlushTimeout)
+  }
+  l.Flush(stopFlushTimeout)
+}
+
+func (l *Logger) doPrintln(level LogLevel, v ...interface{}) {
+  l.doPrintlnN(3, level, nil, v...)
   //if l.logger == nil {
   //  return
   //}
@@ -507,10 +644,22 @@ func getRuntimeInfo(callDepth int) (string, string, int) {
 }
 
 func SetLevel(l *Logger, level LogLevel) Logger {
-  l.level = level
+  if l.state != nil {
+    l.state.level.Store(int32(level))
+  } else {
+    l.level = level
+  }
   return *l
 }
 
+// Level returns the current level of the logger.
+func (l *Logger) Level() LogLevel {
+  if l.state == nil {
+    return l.level
+  }
+  return LogLevel(l.state.level.Load())
+}
+
 // DebugLevel sets log level to debug.
 func DebugLevel(l Logger) Logger {
   l.level = DEBUG
@@ -582,70 +731,84 @@ func PrintStack(l Logger) Logger {
 
 // Tracef prints formatted trace log.
 func Tracef(format string, v ...interface{}) {
-  loggerInstance.Tracef(format, v...)
+  pkgAdaptor().Tracef(format, v...)
 }
 
 // Debugf prints formatted debug log.
 func Debugf(format string, v ...interface{}) {
-  loggerInstance.Debugf(format, v...)
+  pkgAdaptor().Debugf(format, v...)
 }
 
 // Infof prints formatted info log.
 func Infof(format string, v ...interface{}) {
-  loggerInstance.Infof(format, v...)
+  pkgAdaptor().Infof(format, v...)
 }
 
 // Warnf prints formatted warn log.
 func Warnf(format string, v ...interface{}) {
-  loggerInstance.Warnf(format, v...)
+  pkgAdaptor().Warnf(format, v...)
 }
 
 // Errorf prints formatted error log.
 func Errorf(format string, v ...interface{}) {
-  loggerInstance.Errorf(format, v...)
+  pkgAdaptor().Errorf(format, v...)
 }
 
 // Fatalf prints formatted fatal log and exits.
 func Fatalf(format string, v ...interface{}) {
-  loggerInstance.Fatalf(format, v...)
+  fatalWithoutDefault(fmt.Sprintf(format, v...), nil)
+  pkgAdaptor().Fatalf(format, v...)
   os.Exit(1)
 }
 
 // Traceln prints debug log.
 func Traceln(v ...interface{}) {
-  loggerInstance.Traceln(v...)
+  pkgAdaptor().Traceln(v...)
 }
 
 // Debugln prints debug log.
 func Debugln(v ...interface{}) {
-  loggerInstance.Debugln(v...)
+  pkgAdaptor().Debugln(v...)
 }
 
 // Infoln prints info log.
 func Infoln(v ...interface{}) {
-  loggerInstance.Infoln(v...)
+  pkgAdaptor().Infoln(v...)
 }
 
 // Warnln prints warn log.
 func Warnln(v ...interface{}) {
-  loggerInstance.Warnln(v...)
+  pkgAdaptor().Warnln(v...)
 }
 
 // Errorln prints error log.
 func Errorln(v ...interface{}) {
-  loggerInstance.Errorln(v...)
+  pkgAdaptor().Errorln(v...)
 }
 
 // Fatalln prints fatal log and exits.
 func Fatalln(v ...interface{}) {
-  loggerInstance.Fatalln(v...)
+  fatalWithoutDefault(fmt.Sprintln(v...), nil)
+  pkgAdaptor().Fatalln(v...)
+  os.Exit(1)
+}
+
+// fatalWithoutDefault writes a fatal record to stderr and exits when there
+// is no default logger to log it.
+func fatalWithoutDefault(msg string, fields []Field) {
+  if std.Load() != nil {
+    return
+  }
+  buf := getBuffer()
+  TextEncoder.Encode(buf, &Record{Time: time.Now(), Level: FATAL, Message: strings.TrimSuffix(msg, "\n"), Fields: fields})
+  os.Stderr.Write(buf.Bytes())
   os.Exit(1)
 }
 
 func Write(p []byte) {
-  loggerInstance.Write(p)
+  pkgAdaptor().Write(p)
 }
 
 func SetCallDepth(callDepth int) {
-  loggerInstance.SetCallDepth(callDepth)
+  pkgAdaptor().SetCallDepth(callDepth)
 }
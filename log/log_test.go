@@ -1,6 +1,15 @@
 package log
 
 import (
+  "bytes"
+  "context"
+  "encoding/json"
+  "errors"
+  "os"
+  "os/exec"
+  "path"
+  "strings"
+  "sync"
   "testing"
   "time"
 )
@@ -22,19 +31,36 @@ func EverySecond(l Logger) Logger {
   return l
 }
 
+// fakeClock is a clock moved by hand.
+type fakeClock struct {
+  mu sync.Mutex
+  t  time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+  c.mu.Lock()
+  defer c.mu.Unlock()
+  return c.t
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+  c.mu.Lock()
+  defer c.mu.Unlock()
+  c.t = c.t.Add(d)
+}
+
 func TestFileLoggerEveryMinute(t *testing.T) {
-  defer Start(LogFilePath("./log", ""), EveryMinute).Stop()
+  dir := t.TempDir()
+  clock := &fakeClock{t: time.Date(2024, 3, 1, 12, 0, 30, 0, time.Local)}
+  defer Start(LogFilePath(dir, "minute.log"), EveryMinute, LogClock(clock.Now)).Stop()
 
   {
-    // after one minute a new log created, uncomment it to have a try!
-    //time.Sleep(time.Second*time.Duration(10))
-
     Infof("%s", "Jingle bells, jingle bells,")
     Warnf("%s", "Jingle all the way.")
     Errorf("%s", "Oh! what fun it is to ride")
     Infof("%s", "In a one-horse open sleigh.")
   }
-  time.Sleep(time.Minute)
+  clock.Add(time.Minute)
   {
     Traceln("hello tracer")
     Debugln("hello debuger")
@@ -43,7 +69,7 @@ func TestFileLoggerEveryMinute(t *testing.T) {
     Errorln("You have to go through it until sunshine comes out")
     Infoln("Those were the days hard work forever pays")
   }
-  time.Sleep(time.Minute)
+  clock.Add(time.Minute)
   {
     Traceln("hello tracer")
     Debugln("hello debuger")
@@ -53,4 +79,361 @@ func TestFileLoggerEveryMinute(t *testing.T) {
     Infoln("Those were the days hard work forever pays")
   }
 
+  for name, want := range map[string]int{
+    "minute.2024-03-01-12-00.log": 4,
+    "minute.2024-03-01-12-01.log": 6,
+    "minute.log":                  6,
+  } {
+    data, err := os.ReadFile(path.Join(dir, name))
+    if err != nil {
+      t.Fatal(err)
+    }
+    lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+    if len(lines) != want {
+      t.Errorf("%s: got %d lines, want %d", name, len(lines), want)
+    }
+  }
+  data, _ := os.ReadFile(path.Join(dir, "minute.log"))
+  if !strings.HasPrefix(string(data), "2024/03/01 12:02:30.000000 TRC: hello tracer") {
+    t.Errorf("unexpected log %q", data)
+  }
+}
+
+func TestTimeLayout(t *testing.T) {
+  clock := &fakeClock{t: time.Date(2024, 3, 1, 12, 0, 30, 5000, time.FixedZone("X", 3600))}
+  for _, tc := range []struct {
+    decorators []func(Logger) Logger
+    enc        Encoder
+    want       string
+  }{
+    {nil, TextEncoder, "2024/03/01 12:00:30.000005 INF: hi\n"},
+    {[]func(Logger) Logger{LogUTC}, TextEncoder, "2024/03/01 11:00:30.000005 INF: hi\n"},
+    {[]func(Logger) Logger{LogTimeLayout(time.RFC3339Nano)}, TextEncoder, "2024-03-01T12:00:30.000005+01:00 INF: hi\n"},
+    {[]func(Logger) Logger{LogUTC, LogTimeLayout(time.RFC3339Nano)}, TextEncoder, "2024-03-01T11:00:30.000005Z INF: hi\n"},
+    {[]func(Logger) Logger{LogTimeLayout(EpochMillis)}, JSONEncoder, `{"ts":1709290830000,"level":"INFO","msg":"hi"}` + "\n"},
+    {[]func(Logger) Logger{LogTimeLayout("15:04 MST")}, LogfmtEncoder, `ts="12:00 X" level=INF msg=hi` + "\n"},
+  } {
+    var buf bytes.Buffer
+    decorators := append(tc.decorators, LogClock(clock.Now), LogFilePath(t.TempDir(), "time.log"),
+      LogSink(NewWriterSink(&buf, tc.enc), TRACE))
+    inst := NewLogInstance(decorators...)
+    NewAdaptorFromInstance(inst, 3).Infof("hi")
+    inst.Stop()
+    if buf.String() != tc.want {
+      t.Errorf("got %q, want %q", buf.String(), tc.want)
+    }
+  }
+}
+
+func TestAdaptorWithFields(t *testing.T) {
+  dir := t.TempDir()
+  inst := NewLogInstance(LogFilePath(dir, "fields.log"), LogFlags(Lfile|Lline))
+  l := NewAdaptorFromInstance(inst, 3)
+  child := l.With("request_id", "r-1", Int("user", 42))
+  child.Infof("hello %s", "world")
+  child.Infow("done", "took", time.Second, "note", "two words")
+  l.Infoln("parent")
+  l.Stop()
+
+  data, err := os.ReadFile(path.Join(dir, "fields.log"))
+  if err != nil {
+    t.Fatal(err)
+  }
+  lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+  if len(lines) != 3 {
+    t.Fatalf("got %d lines: %q", len(lines), data)
+  }
+  if !strings.HasSuffix(lines[0], "hello world request_id=r-1 user=42") ||
+    !strings.Contains(lines[0], "(log_test.go:") {
+    t.Errorf("unexpected line %q", lines[0])
+  }
+  if !strings.HasSuffix(lines[1], `done request_id=r-1 user=42 took=1s note="two words"`) {
+    t.Errorf("unexpected line %q", lines[1])
+  }
+  if !strings.HasSuffix(lines[2], "parent") {
+    t.Errorf("unexpected line %q", lines[2])
+  }
+}
+
+func TestPackageWith(t *testing.T) {
+  dir := t.TempDir()
+  SetDefault(New(LogFilePath(dir, "with.log"), LogFlags(Lfile|Lline)))
+  With("k", "v").Infof("with")
+  WithFields(Int("n", 1)).Infow("fields")
+  Stop()
+
+  data, _ := os.ReadFile(path.Join(dir, "with.log"))
+  lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+  if len(lines) != 2 {
+    t.Fatalf("got %d lines: %q", len(lines), data)
+  }
+  for _, line := range lines {
+    if !strings.Contains(line, "[log.TestPackageWith] (log_test.go:") {
+      t.Errorf("wrong caller: %q", line)
+    }
+  }
+}
+
+func TestPrintfUnfiltered(t *testing.T) {
+  dir := t.TempDir()
+  var jsonBuf bytes.Buffer
+  inst := NewLogInstance(LogFilePath(dir, "printf.log"), ErrorLevel, LogFlags(Lfile|Lline),
+    LogSink(NewWriterSink(&jsonBuf, JSONEncoder), FATAL))
+  inst.Printf("raw %d", 1)
+  NewAdaptorFromInstance(inst, 3).Printf("raw %d", 2)
+  inst.Stop()
+
+  data, _ := os.ReadFile(path.Join(dir, "printf.log"))
+  lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+  if len(lines) != 2 || !strings.HasSuffix(lines[0], " raw 1") || !strings.HasSuffix(lines[1], " raw 2") ||
+    strings.Contains(string(data), "DBG") || strings.Contains(string(data), "log_test.go") {
+    t.Errorf("got %q", data)
+  }
+  if !strings.HasSuffix(jsonBuf.String(), `,"msg":"raw 2"}`+"\n") || strings.Contains(jsonBuf.String(), "level") {
+    t.Errorf("json sink got %q", jsonBuf.String())
+  }
+}
+
+func TestJSONEncoder(t *testing.T) {
+  dir := t.TempDir()
+  inst := NewLogInstance(LogFilePath(dir, "json.log"), LogFlags(Lfile|Lline), LogEncoder(JSONEncoder))
+  l := NewAdaptorFromInstance(inst, 3)
+  l.With("user", 42).Warnw("quote \" and\nnewline", "ok", true, "err", errors.New("boom"))
+  inst.Stop()
+
+  data, err := os.ReadFile(path.Join(dir, "json.log"))
+  if err != nil {
+    t.Fatal(err)
+  }
+  var m map[string]interface{}
+  if err := json.Unmarshal(data, &m); err != nil {
+    t.Fatalf("invalid json %q: %v", data, err)
+  }
+  if m["level"] != "WARN" || m["msg"] != "quote \" and\nnewline" || m["user"] != 42.0 ||
+    m["ok"] != true || m["err"] != "boom" || m["func"] != "log.TestJSONEncoder" ||
+    !strings.HasPrefix(m["caller"].(string), "log_test.go:") || m["ts"] == nil {
+    t.Errorf("unexpected record %v", m)
+  }
+}
+
+func TestLogfmtEncoder(t *testing.T) {
+  var buf bytes.Buffer
+  r := Record{
+    Level:   INFO,
+    File:    "/src/app/main.go",
+    Line:    12,
+    Func:    "github.com/x/app.Run",
+    Message: `say "hi"`,
+    Fields: []Field{
+      String("path", "/a b"),
+      String("multi", "one\ntwo"),
+      Int("n", 3),
+      String("empty", ""),
+      String("bad key", "x=y"),
+    },
+  }
+  LogfmtEncoder.Encode(&buf, &r)
+  want := `level=INF caller=main.go:12 func=app.Run msg="say \"hi\"" path="/a b" multi="one\ntwo" n=3 empty="" bad_key="x=y"` + "\n"
+  if buf.String() != want {
+    t.Errorf("got  %s\nwant %s", buf.String(), want)
+  }
+}
+
+func TestLogSinkLevels(t *testing.T) {
+  dir := t.TempDir()
+  errSink, err := NewFileSink(LogFilePath(dir, "errors.log"), LogEncoder(JSONEncoder))
+  if err != nil {
+    t.Fatal(err)
+  }
+  var warnBuf bytes.Buffer
+  inst := NewLogInstance(LogFilePath(dir, "all.log"), InfoLevel,
+    LogSink(NewWriterSink(&warnBuf, LogfmtEncoder), WARN),
+    LogSink(errSink, ERROR))
+  l := NewAdaptorFromInstance(inst, 3)
+  l.Debugln("dropped everywhere")
+  l.Infoln("info")
+  l.Warnln("warn")
+  l.Errorln("error")
+  inst.Stop()
+
+  count := func(data string) int {
+    return len(strings.Split(strings.TrimSpace(data), "\n"))
+  }
+  all, _ := os.ReadFile(path.Join(dir, "all.log"))
+  errs, _ := os.ReadFile(path.Join(dir, "errors.log"))
+  if n := count(string(all)); n != 3 {
+    t.Errorf("all.log has %d lines: %q", n, all)
+  }
+  if n := count(warnBuf.String()); n != 2 || !strings.HasPrefix(warnBuf.String(), "ts=") {
+    t.Errorf("warn sink has %d lines: %q", n, warnBuf.String())
+  }
+  if n := count(string(errs)); n != 1 || !strings.Contains(string(errs), `"msg":"error"`) {
+    t.Errorf("errors.log has %d lines: %q", n, errs)
+  }
+}
+
+func TestConcurrentLogger(t *testing.T) {
+  for _, async := range []bool{false, true} {
+    dir := t.TempDir()
+    decorators := []func(Logger) Logger{LogFilePath(dir, "race.log"), MaxSize(4 << 10), MaxBackups(2), LogFlags(Lfile | Lline)}
+    if async {
+      decorators = append(decorators, Async(64, Block))
+    }
+    inst := NewLogInstance(decorators...)
+    l := NewAdaptorFromInstance(inst, 3)
+
+    var wg sync.WaitGroup
+    stop := make(chan struct{})
+    for i := 0; i < 4; i++ {
+      wg.Add(1)
+      go func(i int) {
+        defer wg.Done()
+        named := l.Named("worker")
+        for n := 0; ; n++ {
+          select {
+          case <-stop:
+            return
+          default:
+          }
+          named.Infof("record %d from %d", n, i)
+          l.With("n", n).Warnw("fields")
+        }
+      }(i)
+    }
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      levels := []LogLevel{DEBUG, WARN, INFO}
+      for n := 0; n < 200; n++ {
+        l.SetLevel(levels[n%len(levels)])
+        inst.SetLevelRules("worker=ERROR")
+        inst.SetLevelRules("")
+        inst.Reopen()
+      }
+    }()
+    time.Sleep(50 * time.Millisecond)
+
+    // every copy stops the same logger, only once
+    copies := []*Logger{inst, l.Named("other").logger}
+    for _, c := range copies {
+      wg.Add(1)
+      go func(c *Logger) {
+        defer wg.Done()
+        c.Stop()
+        c.Stop()
+      }(c)
+    }
+    time.Sleep(10 * time.Millisecond)
+    close(stop)
+    wg.Wait()
+
+    before, _ := os.ReadFile(path.Join(dir, "race.log"))
+    l.Errorf("after stop")
+    after, _ := os.ReadFile(path.Join(dir, "race.log"))
+    if !bytes.Equal(before, after) {
+      t.Errorf("async=%v: record written after Stop", async)
+    }
+    backups, _ := os.ReadDir(dir)
+    if len(backups) < 2 {
+      t.Errorf("async=%v: no rotation, files %v", async, backups)
+    }
+  }
+}
+
+func TestIndependentInstances(t *testing.T) {
+  // without a default logger the package-level functions do nothing
+  Infof("nowhere %d", 1)
+  Infow("nowhere")
+  Infoc(WithContext(context.Background(), "k", "v"), "nowhere")
+  With("k", "v").Named("lib").Errorln("nowhere")
+  CaptureStdLog(CaptureConfig{Level: INFO})()
+  if !Flush(time.Second) || Reopen() != nil || SetLevelRules("x=DEBUG") != nil {
+    t.Error("no-op default logger failed")
+  }
+  Stop()
+  // adaptors made before there is a default follow it once set
+  early := NewAdaptor(3)
+  lib := early.Named("lib")
+  db := Named("db")
+  with := With("k", "v")
+
+  dir := t.TempDir()
+  a := New(LogFilePath(dir, "a.log"), LogFlags(Lfile|Lline))
+  b := New(LogFilePath(dir, "b.log"), WarnLevel, EveryHour)
+  SetDefault(a)
+  Infof("to a")
+  early.Infof("early to a")
+  lib.Infow("lib to a")
+  db.Infof("db to a")
+  with.Infof("with to a")
+  b.Infof("dropped by b")
+  b.Warnf("to b")
+  b.Stop()
+  Infow("still to a")
+  if Default() != a {
+    t.Error("Default is not a")
+  }
+  a.Stop()
+  Infof("nowhere")
+  Start().Stop()
+
+  data, _ := os.ReadFile(path.Join(dir, "a.log"))
+  lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+  if len(lines) != 6 || !strings.HasSuffix(lines[5], "still to a") ||
+    !strings.HasSuffix(lines[2], "lib to a logger=lib") || !strings.HasSuffix(lines[3], "db to a logger=db") ||
+    !strings.HasSuffix(lines[4], "with to a k=v") {
+    t.Errorf("a.log: %q", data)
+  }
+  for _, line := range lines {
+    if !strings.Contains(line, "[log.TestIndependentInstances] (log_test.go:") {
+      t.Errorf("wrong caller: %q", line)
+    }
+  }
+  data, _ = os.ReadFile(path.Join(dir, "b.log"))
+  if strings.TrimSpace(string(data)) == "" || strings.Contains(string(data), "dropped") {
+    t.Errorf("b.log: %q", data)
+  }
+}
+
+func TestSetDefaultFollower(t *testing.T) {
+  // a follower without a default is no default either
+  SetDefault(NewAdaptor(3))
+  Infof("nowhere")
+  if SetLevelRules("x=DEBUG") != nil {
+    t.Error("SetLevelRules failed")
+  }
+  Stop()
+
+  dir := t.TempDir()
+  a := New(LogFilePath(dir, "a.log"))
+  SetDefault(a)
+  SetDefault(NewAdaptor(3).Named("app"))
+  Infof("to a")
+  if err := SetLevelRules("app=ERROR"); err != nil {
+    t.Fatal(err)
+  }
+  Infof("dropped")
+  Stop()
+  if Default().current().running() {
+    t.Error("default still set after Stop")
+  }
+
+  data, _ := os.ReadFile(path.Join(dir, "a.log"))
+  if line := strings.TrimSpace(string(data)); !strings.HasSuffix(line, "to a logger=app") {
+    t.Errorf("a.log: %q", data)
+  }
+}
+
+func TestFatalWithoutDefault(t *testing.T) {
+  if os.Getenv("LOG_TEST_FATAL") == "1" {
+    Fatalw("boom", "k", "v")
+    return
+  }
+  cmd := exec.Command(os.Args[0], "-test.run=^TestFatalWithoutDefault$")
+  cmd.Env = append(os.Environ(), "LOG_TEST_FATAL=1")
+  out, err := cmd.CombinedOutput()
+  var exit *exec.ExitError
+  if !errors.As(err, &exit) || exit.ExitCode() != 1 || !strings.Contains(string(out), "FTL: boom k=v") {
+    t.Errorf("got %v: %q", err, out)
+  }
 }
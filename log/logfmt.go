@@ -0,0 +1,62 @@
+package log
+
+import (
+  "bytes"
+  "path"
+  "strconv"
+  "strings"
+)
+
+// LogfmtEncoder produces logfmt lines:
+// ts=2006-01-02T15:04:05.000000Z07:00 level=INF caller=file.go:12 func=pkg.Fn msg="..." k=v
+var LogfmtEncoder Encoder = EncoderFunc(encodeLogfmt)
+
+func encodeLogfmt(buf *bytes.Buffer, r *Record) {
+  if !r.Time.IsZero() {
+    buf.WriteString("ts=")
+    buf.WriteString(quoteIfNeeded(r.timeString("2006-01-02T15:04:05.000000Z07:00")))
+    buf.WriteByte(' ')
+  }
+  if r.raw {
+    buf.WriteString("msg=")
+    buf.WriteString(quoteIfNeeded(r.Message))
+    buf.WriteByte('\n')
+    return
+  }
+  buf.WriteString("level=")
+  buf.WriteString(tagName[r.Level])
+  if r.File != "" {
+    buf.WriteString(" caller=")
+    caller := path.Base(r.File)
+    if r.Line > 0 {
+      caller += ":" + strconv.Itoa(r.Line)
+    }
+    buf.WriteString(quoteIfNeeded(caller))
+  }
+  if r.Func != "" {
+    buf.WriteString(" func=")
+    buf.WriteString(quoteIfNeeded(path.Base(r.Func)))
+  }
+  buf.WriteString(" msg=")
+  buf.WriteString(quoteIfNeeded(r.Message))
+  walkFields("", r.Fields, func(key string, v interface{}) {
+    buf.WriteByte(' ')
+    buf.WriteString(logfmtKey(key))
+    buf.WriteByte('=')
+    buf.WriteString(quoteIfNeeded(fieldString(v)))
+  })
+  buf.WriteByte('\n')
+}
+
+// logfmtKey replaces the characters a logfmt key can't hold.
+func logfmtKey(key string) string {
+  if key == "" {
+    return "_"
+  }
+  return strings.Map(func(r rune) rune {
+    if r <= ' ' || r == '=' || r == '"' || r == 0x7f {
+      return '_'
+    }
+    return r
+  }, key)
+}
@@ -0,0 +1,332 @@
+package log
+
+import (
+  "fmt"
+  "os"
+  "path"
+  "runtime"
+  "strings"
+  "sync"
+)
+
+// LoggerKey is the key of the field holding the name of a named logger.
+const LoggerKey = "logger"
+
+const (
+  ruleName = iota
+  ruleFile
+  ruleFunc
+)
+
+type levelRule struct {
+  kind    int
+  pattern string
+  level   LogLevel
+}
+
+type ruleLevel struct {
+  level LogLevel
+  ok    bool
+}
+
+// levelRules overrides the logger level by logger name or caller.
+type levelRules struct {
+  spec    string
+  names   []levelRule
+  callers []levelRule
+  byName  sync.Map // logger name -> ruleLevel
+  byPC    sync.Map // caller pc -> ruleLevel
+}
+
+// parseLevelRules parses comma separated pattern=LEVEL rules. A pattern is
+// a logger name, matching its descendants too, "file:" followed by a source
+// file, or "func:" followed by a function name. '*' matches any sequence of
+// characters and '?' any single one, e.g. "db.*=DEBUG,http=WARN,
+// file:pool.go=TRACE,func:*.(*Server).serve=ERROR".
+func parseLevelRules(spec string) (*levelRules, error) {
+  rs := &levelRules{spec: spec}
+  for _, item := range strings.Split(spec, ",") {
+    item = strings.TrimSpace(item)
+    if item == "" {
+      continue
+    }
+    i := strings.LastIndex(item, "=")
+    if i < 0 {
+      return nil, fmt.Errorf("log: level rule %q without level", item)
+    }
+    level, err := ParseLevel(item[i+1:])
+    if err != nil {
+      return nil, err
+    }
+    rule := levelRule{pattern: strings.TrimSpace(item[:i]), level: level}
+    switch {
+    case strings.HasPrefix(rule.pattern, "file:"):
+      rule.kind, rule.pattern = ruleFile, rule.pattern[len("file:"):]
+    case strings.HasPrefix(rule.pattern, "func:"):
+      rule.kind, rule.pattern = ruleFunc, rule.pattern[len("func:"):]
+    }
+    if rule.kind == ruleName {
+      rs.names = append(rs.names, rule)
+    } else if rule.pattern == "" {
+      return nil, fmt.Errorf("log: level rule %q without pattern", item)
+    } else {
+      rs.callers = append(rs.callers, rule)
+    }
+  }
+  return rs, nil
+}
+
+// forName returns the level of the most specific rule matching a logger
+// name, the last one among equally long patterns.
+func (rs *levelRules) forName(name string) (LogLevel, bool) {
+  if v, ok := rs.byName.Load(name); ok {
+    rl := v.(ruleLevel)
+    return rl.level, rl.ok
+  }
+  var rl ruleLevel
+  best := -1
+  for _, rule := range rs.names {
+    if len(rule.pattern) >= best &&
+      (globMatch(rule.pattern, name) || globMatch(rule.pattern+".*", name)) {
+      rl = ruleLevel{level: rule.level, ok: true}
+      best = len(rule.pattern)
+    }
+  }
+  rs.byName.Store(name, rl)
+  return rl.level, rl.ok
+}
+
+// forCaller returns the level of the first file or func rule matching the
+// caller at pc.
+func (rs *levelRules) forCaller(pc uintptr) (LogLevel, bool) {
+  if v, ok := rs.byPC.Load(pc); ok {
+    rl := v.(ruleLevel)
+    return rl.level, rl.ok
+  }
+  frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+  var rl ruleLevel
+  rl.level, rl.ok = rs.forFrame(frame)
+  rs.byPC.Store(pc, rl)
+  return rl.level, rl.ok
+}
+
+// forFrame returns the level of the first caller rule matching frame.
+func (rs *levelRules) forFrame(frame runtime.Frame) (LogLevel, bool) {
+  for _, rule := range rs.callers {
+    target := frame.Function
+    if rule.kind == ruleFile {
+      target = frame.File
+    }
+    if matchPath(rule.pattern, target) {
+      return rule.level, true
+    }
+  }
+  return 0, false
+}
+
+// matchPath matches a pattern against the base name of s when it has no
+// '/', otherwise against the trailing elements of s.
+func matchPath(pattern, s string) bool {
+  if !strings.Contains(pattern, "/") {
+    return globMatch(pattern, path.Base(s))
+  }
+  return globMatch(pattern, s) || globMatch("*/"+pattern, s)
+}
+
+// globMatch reports whether s matches pattern, where '*' matches any
+// sequence of characters and '?' any single one.
+func globMatch(pattern, s string) bool {
+  px, sx := 0, 0
+  star, next := -1, 0
+  for sx < len(s) {
+    if px < len(pattern) {
+      switch c := pattern[px]; c {
+      case '*':
+        star, next = px, sx
+        px++
+        continue
+      case '?':
+        px++
+        sx++
+        continue
+      default:
+        if c == s[sx] {
+          px++
+          sx++
+          continue
+        }
+      }
+    }
+    if star < 0 {
+      return false
+    }
+    next++
+    px, sx = star+1, next
+  }
+  for px < len(pattern) && pattern[px] == '*' {
+    px++
+  }
+  return px == len(pattern)
+}
+
+// enabled reports whether a record at level from the caller callDepth
+// frames up is logged, given the level rules.
+func (l *Logger) enabled(callDepth int, level LogLevel) bool {
+  var rs *levelRules
+  if l.state != nil {
+    rs = l.state.rules.Load()
+  }
+  if rs == nil {
+    return level >= l.Level()
+  }
+  if len(rs.callers) > 0 {
+    var pcs [1]uintptr
+    if runtime.Callers(callDepth+1, pcs[:]) > 0 {
+      if min, ok := rs.forCaller(pcs[0]); ok {
+        return level >= min
+      }
+    }
+  }
+  return level >= l.nameLevel(rs)
+}
+
+// enabledFrame is enabled for a caller already known as frame.
+func (l *Logger) enabledFrame(frame runtime.Frame, level LogLevel) bool {
+  var rs *levelRules
+  if l.state != nil {
+    rs = l.state.rules.Load()
+  }
+  if rs == nil {
+    return level >= l.Level()
+  }
+  if min, ok := rs.forFrame(frame); ok {
+    return level >= min
+  }
+  return level >= l.nameLevel(rs)
+}
+
+// nameLevel returns the level of the logger name under rs.
+func (l *Logger) nameLevel(rs *levelRules) LogLevel {
+  if rs != nil {
+    if min, ok := rs.forName(l.loggerName); ok {
+      return min
+    }
+  }
+  return l.Level()
+}
+
+// namedLevel returns the level of the logger after the name rules.
+func (l *Logger) namedLevel() LogLevel {
+  if l.state == nil {
+    return l.Level()
+  }
+  return l.nameLevel(l.state.rules.Load())
+}
+
+// SetLevelRules replaces the level rules of the logger, see LevelRules. An
+// empty spec removes them.
+func (l *Logger) SetLevelRules(spec string) error {
+  if l.state == nil {
+    return nil
+  }
+  rs, err := parseLevelRules(spec)
+  if err != nil {
+    return err
+  }
+  if len(rs.names) == 0 && len(rs.callers) == 0 {
+    rs = nil
+  }
+  l.state.rules.Store(rs)
+  return nil
+}
+
+// LevelRules returns the current level rules of the logger.
+func (l *Logger) LevelRules() string {
+  if l.state == nil {
+    return ""
+  }
+  if rs := l.state.rules.Load(); rs != nil {
+    return rs.spec
+  }
+  return ""
+}
+
+// LevelRules returns a function to set level rules overriding the logger
+// level, comma separated pattern=LEVEL items:
+//
+//	db.*=DEBUG               loggers named db.something and below
+//	http=WARN                the http logger and below
+//	file:pool.go=TRACE       calls from the files named pool.go
+//	func:*.(*Server).*=ERROR calls from the methods of Server types
+//
+// Among name rules the longest matching pattern wins. File and func rules
+// are tried first, in order.
+func LevelRules(spec string) func(Logger) Logger {
+  return func(l Logger) Logger {
+    l.ruleSpec = spec
+    return l
+  }
+}
+
+// newRules returns the initial rules of a new logger, nil without any.
+func newRules(spec string) *levelRules {
+  rs, err := parseLevelRules(spec)
+  if err != nil {
+    fmt.Fprintln(os.Stderr, err)
+    return nil
+  }
+  if len(rs.names) > 0 || len(rs.callers) > 0 {
+    return rs
+  }
+  return nil
+}
+
+// Named returns a child adaptor logging under name, appended to the name of
+// l with a dot. The full name is emitted in the LoggerKey field and selects
+// the level rules.
+func (l *LogAdaptor) Named(name string) *LogAdaptor {
+  child := *l
+  logger := *l.current()
+  if logger.loggerName != "" {
+    name = logger.loggerName + "." + name
+  }
+  if l.std != nil {
+    child.name = name
+  } else {
+    logger.loggerName = name
+    child.logger = &logger
+  }
+  child.fields = make([]Field, 0, len(l.fields)+1)
+  for _, f := range l.fields {
+    if f.Key != LoggerKey {
+      child.fields = append(child.fields, f)
+    }
+  }
+  child.fields = append(child.fields, String(LoggerKey, name))
+  return &child
+}
+
+// Name returns the name of the logger.
+func (l *LogAdaptor) Name() string {
+  return l.current().loggerName
+}
+
+// SetLevelRules replaces the level rules of the logger.
+func (l *LogAdaptor) SetLevelRules(spec string) error {
+  return l.current().SetLevelRules(spec)
+}
+
+// LevelRules returns the current level rules of the logger.
+func (l *LogAdaptor) LevelRules() string {
+  return l.current().LevelRules()
+}
+
+// Named returns a child of the default adaptor logging under name.
+func Named(name string) *LogAdaptor {
+  return Default().Named(name)
+}
+
+// SetLevelRules replaces the level rules of the default logger.
+func SetLevelRules(spec string) error {
+  return pkgAdaptor().current().SetLevelRules(spec)
+}
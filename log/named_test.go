@@ -0,0 +1,90 @@
+package log
+
+import (
+  "os"
+  "path"
+  "strings"
+  "testing"
+)
+
+func TestNamedLevelRules(t *testing.T) {
+  dir := t.TempDir()
+  inst := NewLogInstance(LogFilePath(dir, "named.log"), InfoLevel,
+    LevelRules("db.*=DEBUG,db.pool.conn=ERROR,http=WARN"))
+  l := NewAdaptorFromInstance(inst, 3)
+
+  db := l.Named("db")
+  pool := db.Named("pool")
+  db.Debugf("db debug")         // dropped, db.* doesn't match db
+  pool.Debugf("pool debug")     // kept by db.*
+  pool.Named("conn").Warnf("x") // dropped by db.pool.conn
+  l.Named("http").Named("mux").Infof("http info")
+  l.Named("http").Warnf("http warn")
+  l.Debugf("root debug")
+
+  if err := l.SetLevelRules("func:*.TestNamedLevelRules=TRACE"); err != nil {
+    t.Fatal(err)
+  }
+  l.Tracef("traced")
+  if err := l.SetLevelRules("file:other.go=TRACE,nope"); err == nil {
+    t.Error("invalid rules accepted")
+  }
+  if l.LevelRules() != "func:*.TestNamedLevelRules=TRACE" {
+    t.Errorf("rules %q", l.LevelRules())
+  }
+  l.SetLevelRules("")
+  l.Tracef("not traced")
+  l.Stop()
+
+  data, err := os.ReadFile(path.Join(dir, "named.log"))
+  if err != nil {
+    t.Fatal(err)
+  }
+  lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+  want := []string{
+    "pool debug logger=db.pool",
+    "http warn logger=http",
+    "traced",
+  }
+  if len(lines) != len(want) {
+    t.Fatalf("got %d lines: %q", len(lines), data)
+  }
+  for i, line := range lines {
+    if !strings.HasSuffix(line, want[i]) {
+      t.Errorf("got %q, want suffix %q", line, want[i])
+    }
+  }
+}
+
+func TestGlobMatch(t *testing.T) {
+  for _, tc := range []struct {
+    pattern, s string
+    want       bool
+  }{
+    {"db.*", "db.pool", true},
+    {"db.*", "db", false},
+    {"*.go", "a/b.go", true},
+    {"p?ol", "pool", true},
+    {"a*b*c", "axxbyyc", true},
+    {"a*b*c", "axxbyy", false},
+    {"", "", true},
+  } {
+    if got := globMatch(tc.pattern, tc.s); got != tc.want {
+      t.Errorf("globMatch(%q, %q) = %v", tc.pattern, tc.s, got)
+    }
+  }
+}
+
+func TestPackageNamed(t *testing.T) {
+  dir := t.TempDir()
+  SetDefault(New(LogFilePath(dir, "named.log"), LogFlags(Lfile|Lline),
+    LevelRules("file:named_test.go=DEBUG")))
+  Named("db").Debugf("db debug")
+  Stop()
+
+  data, _ := os.ReadFile(path.Join(dir, "named.log"))
+  if line := strings.TrimSpace(string(data)); !strings.Contains(line, "[log.TestPackageNamed] (named_test.go:") ||
+    !strings.HasSuffix(line, "db debug logger=db") {
+    t.Errorf("got %q", data)
+  }
+}
@@ -0,0 +1,328 @@
+package log
+
+import (
+  "bytes"
+  "encoding/json"
+  "fmt"
+  "os"
+  "sort"
+  "sync"
+  "time"
+)
+
+// ConfigFile describes a configuration file watched by NewFromConfigFile.
+type ConfigFile struct {
+  Path string
+  // Interval between two checks of the file, default 5s.
+  Interval time.Duration
+  // Unmarshal decodes the file into a *Config, json.Unmarshal by default.
+  // Pass e.g. yaml.Unmarshal for a YAML file.
+  Unmarshal func(data []byte, v interface{}) error
+  // EnvPrefix, when set, lets the environment override the file, see
+  // Config.LoadEnv.
+  EnvPrefix string
+}
+
+// NewFromConfigFile returns the logger described by a config file, then
+// applies the changes made to the file while running: level, level rules
+// and caller settings are updated in place, and the outputs are swapped
+// without losing or repeating a record. Every reload logs what changed. An
+// invalid file is reported and the running config kept. Sampling changes
+// need a restart.
+func NewFromConfigFile(cf ConfigFile) (*Logger, error) {
+  if cf.Interval <= 0 {
+    cf.Interval = 5 * time.Second
+  }
+  if cf.Unmarshal == nil {
+    cf.Unmarshal = json.Unmarshal
+  }
+  data, info, err := cf.read()
+  if err != nil {
+    return nil, err
+  }
+  c, err := cf.parse(data)
+  if err != nil {
+    return nil, err
+  }
+  decorators, err := outerConfig(c).decorators()
+  if err != nil {
+    return nil, err
+  }
+  inner, err := NewFromConfig(innerConfig(c))
+  if err != nil {
+    return nil, err
+  }
+  s := &reloadSink{
+    cf:      cf,
+    conf:    c,
+    data:    data,
+    info:    info,
+    inner:   inner,
+    done:    make(chan struct{}),
+    stopped: make(chan struct{}),
+  }
+  decorators = append(decorators, func(l Logger) Logger {
+    l.noStderr = true
+    return l
+  }, LogSink(s, TRACE))
+  s.outer = NewLogInstance(decorators...)
+  go s.watch()
+  return s.outer, nil
+}
+
+// outerConfig keeps the settings applied before the records reach the
+// outputs.
+func outerConfig(c Config) Config {
+  return Config{Level: c.Level, LevelRules: c.LevelRules, Caller: c.Caller, Sampling: c.Sampling}
+}
+
+// innerConfig keeps the settings of the outputs.
+func innerConfig(c Config) Config {
+  c.Level, c.LevelRules, c.Caller, c.Sampling = TRACE, "", "", nil
+  return c
+}
+
+func (cf ConfigFile) read() ([]byte, os.FileInfo, error) {
+  info, err := os.Stat(cf.Path)
+  if err != nil {
+    return nil, nil, err
+  }
+  data, err := os.ReadFile(cf.Path)
+  return data, info, err
+}
+
+func (cf ConfigFile) parse(data []byte) (Config, error) {
+  var c Config
+  if err := cf.Unmarshal(data, &c); err != nil {
+    return c, fmt.Errorf("log: %s: %v", cf.Path, err)
+  }
+  if cf.EnvPrefix != "" {
+    if err := c.LoadEnv(cf.EnvPrefix); err != nil {
+      return c, err
+    }
+  }
+  return c, nil
+}
+
+// reloadSink hands the records to the outputs of the current config.
+type reloadSink struct {
+  cf    ConfigFile
+  outer *Logger
+
+  mu    sync.RWMutex
+  inner *Logger
+
+  // used by the watching goroutine only
+  conf Config
+  data []byte
+  info os.FileInfo
+  // set while the file can't be read, to report it once
+  failing bool
+
+  done    chan struct{}
+  stopped chan struct{}
+  once    sync.Once
+}
+
+// WriteRecord writes r to the current outputs. A swap waits for the
+// records being written.
+func (s *reloadSink) WriteRecord(r *Record) error {
+  s.mu.RLock()
+  defer s.mu.RUnlock()
+  s.inner.dispatch(r)
+  return nil
+}
+
+// Reopen reopens the current log files.
+func (s *reloadSink) Reopen() error {
+  s.mu.RLock()
+  defer s.mu.RUnlock()
+  return s.inner.Reopen()
+}
+
+// Flush waits for the records queued by the current outputs.
+func (s *reloadSink) Flush(timeout time.Duration) bool {
+  s.mu.RLock()
+  defer s.mu.RUnlock()
+  return s.inner.Flush(timeout)
+}
+
+// Close stops watching and closes the outputs.
+func (s *reloadSink) Close() error {
+  s.once.Do(func() {
+    close(s.done)
+  })
+  <-s.stopped
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  s.inner.Release()
+  return nil
+}
+
+func (s *reloadSink) watch() {
+  defer close(s.stopped)
+  ticker := time.NewTicker(s.cf.Interval)
+  defer ticker.Stop()
+  for {
+    select {
+    case <-ticker.C:
+      s.check()
+    case <-s.done:
+      return
+    }
+  }
+}
+
+// check reloads the file if it changed.
+func (s *reloadSink) check() {
+  info, err := os.Stat(s.cf.Path)
+  if err != nil {
+    s.fail(err)
+    return
+  }
+  s.failing = false
+  if info.ModTime().Equal(s.info.ModTime()) && info.Size() == s.info.Size() {
+    return
+  }
+  data, info, err := s.cf.read()
+  if err != nil {
+    s.fail(err)
+    return
+  }
+  s.info = info
+  if bytes.Equal(data, s.data) {
+    return
+  }
+  s.data = data
+  if err := s.reload(data); err != nil {
+    s.reject(err)
+  }
+}
+
+// fail reports an error reading the file once until it is readable again.
+func (s *reloadSink) fail(err error) {
+  if !s.failing {
+    s.failing = true
+    s.reject(err)
+  }
+}
+
+func (s *reloadSink) reject(err error) {
+  s.outer.dispatch(&Record{
+    Time:    s.outer.now(),
+    Level:   ERROR,
+    Message: "log config rejected",
+    Fields:  []Field{String("path", s.cf.Path), Err(err)},
+  })
+}
+
+// reload applies the config in data, or nothing if it is invalid.
+func (s *reloadSink) reload(data []byte) error {
+  c, err := s.cf.parse(data)
+  if err != nil {
+    return err
+  }
+  if _, err := outerConfig(c).decorators(); err != nil {
+    return err
+  }
+  changes := diffConfig(s.conf, c)
+  if len(changes) == 0 {
+    return nil
+  }
+  old := innerConfig(s.conf)
+  if next := innerConfig(c); !configEqual(old, next) {
+    inner, err := NewFromConfig(next)
+    if err != nil {
+      return err
+    }
+    s.mu.Lock()
+    prev := s.inner
+    s.inner = inner
+    s.mu.Unlock()
+    prev.Release()
+  }
+  if c.Level != s.conf.Level {
+    SetLevel(s.outer, c.Level)
+  }
+  if c.LevelRules != s.conf.LevelRules {
+    s.outer.SetLevelRules(c.LevelRules)
+  }
+  if c.Caller != s.conf.Caller {
+    flags, _ := configFlags(c.Caller)
+    s.outer.state.flags.Store(flags)
+  }
+  if !configEqual(Config{Sampling: s.conf.Sampling}, Config{Sampling: c.Sampling}) {
+    // the sampler keeps running as started
+    changes = append(changes, String("sampling", "restart required"))
+    c.Sampling = s.conf.Sampling
+  }
+  s.conf = c
+  s.outer.dispatch(&Record{
+    Time:    s.outer.now(),
+    Level:   INFO,
+    Message: "log config reloaded",
+    Fields:  append([]Field{String("path", s.cf.Path)}, changes...),
+  })
+  return nil
+}
+
+func configEqual(a, b Config) bool {
+  ja, _ := json.Marshal(a)
+  jb, _ := json.Marshal(b)
+  return bytes.Equal(ja, jb)
+}
+
+// diffConfig returns a field per changed setting, holding "old -> new".
+func diffConfig(a, b Config) []Field {
+  fa, fb := flattenConfig(a), flattenConfig(b)
+  keys := make([]string, 0, len(fa)+len(fb))
+  for k := range fa {
+    keys = append(keys, k)
+  }
+  for k := range fb {
+    if _, ok := fa[k]; !ok {
+      keys = append(keys, k)
+    }
+  }
+  sort.Strings(keys)
+  var fields []Field
+  for _, k := range keys {
+    va, oka := fa[k]
+    vb, okb := fb[k]
+    if oka && okb && va == vb {
+      continue
+    }
+    if !oka {
+      va = "unset"
+    }
+    if !okb {
+      vb = "unset"
+    }
+    fields = append(fields, String(k, va+" -> "+vb))
+  }
+  return fields
+}
+
+// flattenConfig returns the settings of c by dotted JSON name.
+func flattenConfig(c Config) map[string]string {
+  data, _ := json.Marshal(c)
+  var m map[string]interface{}
+  json.Unmarshal(data, &m)
+  flat := map[string]string{}
+  var walk func(prefix string, m map[string]interface{})
+  walk = func(prefix string, m map[string]interface{}) {
+    for k, v := range m {
+      switch v := v.(type) {
+      case map[string]interface{}:
+        walk(prefix+k+".", v)
+      case string:
+        flat[prefix+k] = v
+      default:
+        b, _ := json.Marshal(v)
+        flat[prefix+k] = string(b)
+      }
+    }
+  }
+  walk("", m)
+  return flat
+}
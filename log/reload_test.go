@@ -0,0 +1,140 @@
+package log
+
+import (
+  "os"
+  "path"
+  "regexp"
+  "strings"
+  "sync"
+  "testing"
+  "time"
+)
+
+func TestNewFromConfigFile(t *testing.T) {
+  dir := t.TempDir()
+  confPath := path.Join(dir, "log.json")
+  writeConf := func(conf string) {
+    t.Helper()
+    conf = strings.ReplaceAll(conf, "DIR", dir)
+    if err := os.WriteFile(confPath, []byte(conf), 0666); err != nil {
+      t.Fatal(err)
+    }
+  }
+  waitFor := func(cond func() bool) {
+    t.Helper()
+    for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(5 * time.Millisecond) {
+      if time.Now().After(deadline) {
+        t.Fatal("config not reloaded")
+      }
+    }
+  }
+  writeConf(`{"level": "info", "file": {"path": "DIR", "name": "a.log"}}`)
+  inst, err := NewFromConfigFile(ConfigFile{Path: confPath, Interval: 10 * time.Millisecond})
+  if err != nil {
+    t.Fatal(err)
+  }
+  l := NewAdaptorFromInstance(inst, 3)
+
+  const n = 2000
+  var wg sync.WaitGroup
+  wg.Add(1)
+  go func() {
+    defer wg.Done()
+    for i := 0; i < n; i++ {
+      l.Infow("record", "i", i)
+      if i == n/4 {
+        writeConf(`{"level": "debug", "caller": "func", "file": {"path": "DIR", "name": "b.log"}}`)
+      }
+      if i%100 == 0 {
+        time.Sleep(time.Millisecond)
+      }
+    }
+  }()
+  waitFor(func() bool { return l.Level() == DEBUG })
+  wg.Wait()
+  l.Debugf("debug on")
+
+  writeConf(`{"level": "loud", "file": {"path": "DIR", "name": "c.log"}}`)
+  waitFor(func() bool {
+    data, _ := os.ReadFile(path.Join(dir, "b.log"))
+    return strings.Contains(string(data), "log config rejected")
+  })
+  if l.Level() != DEBUG {
+    t.Errorf("level changed to %v", l.Level())
+  }
+  l.Stop()
+
+  a, _ := os.ReadFile(path.Join(dir, "a.log"))
+  b, _ := os.ReadFile(path.Join(dir, "b.log"))
+  seen := map[string]int{}
+  for _, m := range regexp.MustCompile(`record i=(\d+)`).FindAllStringSubmatch(string(a)+string(b), -1) {
+    seen[m[1]]++
+  }
+  if len(seen) != n {
+    t.Errorf("got %d records, want %d", len(seen), n)
+  }
+  for i, count := range seen {
+    if count != 1 {
+      t.Errorf("record %s logged %d times", i, count)
+    }
+  }
+  if !strings.Contains(string(b), `log config reloaded path=`+path.Join(dir, "log.json")+` caller="unset -> func"`) ||
+    !strings.Contains(string(b), `file.name="a.log -> b.log" level="INFO -> DEBUG"`) {
+    t.Errorf("diff missing in %s", b)
+  }
+  if !strings.Contains(string(b), "DBG [log.TestNewFromConfigFile]: debug on") {
+    t.Errorf("debug line missing in %s", b)
+  }
+  if _, err := os.Stat(path.Join(dir, "c.log")); err == nil {
+    t.Error("rejected config applied")
+  }
+}
+
+func TestConfigFileMissing(t *testing.T) {
+  dir := t.TempDir()
+  confPath := path.Join(dir, "log.json")
+  writeConf := func(level string) {
+    t.Helper()
+    conf := `{"level": "` + level + `", "file": {"path": "` + dir + `", "name": "a.log"}}`
+    // replaced at once, never read half written
+    if err := os.WriteFile(confPath+".tmp", []byte(conf), 0666); err != nil {
+      t.Fatal(err)
+    }
+    if err := os.Rename(confPath+".tmp", confPath); err != nil {
+      t.Fatal(err)
+    }
+  }
+  writeConf("info")
+  inst, err := NewFromConfigFile(ConfigFile{Path: confPath, Interval: 5 * time.Millisecond})
+  if err != nil {
+    t.Fatal(err)
+  }
+  rejected := func() int {
+    data, _ := os.ReadFile(path.Join(dir, "a.log"))
+    return strings.Count(string(data), "log config rejected")
+  }
+  waitFor := func(cond func() bool) {
+    t.Helper()
+    for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(5 * time.Millisecond) {
+      if time.Now().After(deadline) {
+        t.Fatal("config not checked")
+      }
+    }
+  }
+
+  os.Remove(confPath)
+  waitFor(func() bool { return rejected() > 0 })
+  time.Sleep(50 * time.Millisecond)
+  if n := rejected(); n != 1 {
+    t.Errorf("missing file reported %d times", n)
+  }
+  writeConf("debug")
+  waitFor(func() bool { return inst.Level() == DEBUG })
+  os.Remove(confPath)
+  waitFor(func() bool { return rejected() > 1 })
+  time.Sleep(50 * time.Millisecond)
+  inst.Stop()
+  if n := rejected(); n != 2 {
+    t.Errorf("file removed again reported %d times in total", n)
+  }
+}
@@ -0,0 +1,90 @@
+package log
+
+import (
+  "fmt"
+  "os"
+  "os/signal"
+  "sync"
+)
+
+// reopener is implemented by sinks writing to a file that can be reopened.
+type reopener interface {
+  Reopen() error
+}
+
+// Reopen reopens the log file if w is one.
+func (s *WriterSink) Reopen() error {
+  if r, ok := s.w.(reopener); ok {
+    return r.Reopen()
+  }
+  return nil
+}
+
+// Reopen reopens the log files of the logger and of its sinks, e.g. after
+// they were moved away by logrotate. Concurrent writes wait for the new
+// file, so no line is lost or split.
+func (l *Logger) Reopen() error {
+  var first error
+  for _, s := range l.loadSinks() {
+    if r, ok := s.sink.(reopener); ok {
+      if err := r.Reopen(); err != nil && first == nil {
+        first = err
+      }
+    }
+  }
+  return first
+}
+
+// Reopen reopens the log files of the underlying logger.
+func (l *LogAdaptor) Reopen() error {
+  return l.current().Reopen()
+}
+
+// Reopen reopens the log files of the default logger.
+func Reopen() error {
+  return pkgAdaptor().Reopen()
+}
+
+// ReopenOnSignal returns a function to reopen the log files whenever one of
+// sigs is received, typically syscall.SIGHUP.
+func ReopenOnSignal(sigs ...os.Signal) func(Logger) Logger {
+  return func(l Logger) Logger {
+    l.reopenSignals = sigs
+    return l
+  }
+}
+
+// signalWatcher calls Reopen on signals until stopped.
+type signalWatcher struct {
+  ch   chan os.Signal
+  done chan struct{}
+  once sync.Once
+}
+
+func watchSignals(l *Logger, sigs []os.Signal) *signalWatcher {
+  w := &signalWatcher{
+    ch:   make(chan os.Signal, 1),
+    done: make(chan struct{}),
+  }
+  signal.Notify(w.ch, sigs...)
+  go func() {
+    for {
+      select {
+      case <-w.ch:
+        if err := l.Reopen(); err != nil {
+          fmt.Fprintln(os.Stderr, err)
+        }
+      case <-w.done:
+        return
+      }
+    }
+  }()
+  return w
+}
+
+func (w *signalWatcher) stop() {
+  w.once.Do(func() {
+    signal.Stop(w.ch)
+    close(w.done)
+  })
+}
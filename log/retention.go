@@ -0,0 +1,115 @@
+package log
+
+import (
+  "fmt"
+  "os"
+  "path"
+  "regexp"
+  "sort"
+  "strconv"
+  "strings"
+  "time"
+)
+
+// retention limits the backups kept by a logSegment.
+type retention struct {
+  maxBackups int
+  maxAge     time.Duration
+  maxTotal   int64
+}
+
+func (r retention) enabled() bool {
+  return r.maxBackups > 0 || r.maxAge > 0 || r.maxTotal > 0
+}
+
+// backupPattern matches the backups of the log file name, as produced by
+// logSegment.backupName, optionally compressed by c. The submatches are the
+// time of the period and the sequence number.
+func backupPattern(name string, c Compressor) *regexp.Regexp {
+  ext := path.Ext(name)
+  base := strings.TrimSuffix(name, ext)
+  var compressed string
+  if c != nil {
+    compressed = `(` + regexp.QuoteMeta(c.Ext()) + `)?`
+  }
+  return regexp.MustCompile(`^` + regexp.QuoteMeta(base) +
+    `\.(\d{4}-\d{2}-\d{2}(?:-\d{2}){0,3})(?:\.(\d+))?` + regexp.QuoteMeta(ext) + compressed + `$`)
+}
+
+func (ls *logSegment) cleanup(now time.Time) {
+  entries, err := os.ReadDir(ls.logPath)
+  if err != nil {
+    fmt.Fprintln(os.Stderr, err)
+    return
+  }
+  pattern := backupPattern(path.Base(ls.logFileName), ls.compressor)
+  type backup struct {
+    info   os.FileInfo
+    period string
+    seq    int
+  }
+  var backups []backup
+  for _, entry := range entries {
+    m := pattern.FindStringSubmatch(entry.Name())
+    if !entry.Type().IsRegular() || m == nil {
+      continue
+    }
+    info, err := entry.Info()
+    if err != nil {
+      continue
+    }
+    seq, _ := strconv.Atoi(m[2])
+    backups = append(backups, backup{info: info, period: m[1], seq: seq})
+  }
+  // newest first, by name: modification times tie within a clock tick and
+  // change when a backup is compressed
+  sort.Slice(backups, func(i, j int) bool {
+    if backups[i].period != backups[j].period {
+      return backups[i].period > backups[j].period
+    }
+    return backups[i].seq > backups[j].seq
+  })
+
+  var total int64
+  if info, err := os.Stat(ls.logFileName); err == nil {
+    total = info.Size()
+  }
+  r := ls.retention
+  for i, b := range backups {
+    info := b.info
+    if (r.maxBackups > 0 && i >= r.maxBackups) ||
+      (r.maxAge > 0 && now.Sub(info.ModTime()) > r.maxAge) ||
+      (r.maxTotal > 0 && total+info.Size() > r.maxTotal) {
+      if err := os.Remove(path.Join(ls.logPath, info.Name())); err != nil {
+        fmt.Fprintln(os.Stderr, err)
+      }
+      continue
+    }
+    total += info.Size()
+  }
+}
+
+// MaxBackups returns a function to keep at most n rotated log files.
+func MaxBackups(n int) func(Logger) Logger {
+  return func(l Logger) Logger {
+    l.retention.maxBackups = n
+    return l
+  }
+}
+
+// MaxAge returns a function to delete rotated log files older than d.
+func MaxAge(d time.Duration) func(Logger) Logger {
+  return func(l Logger) Logger {
+    l.retention.maxAge = d
+    return l
+  }
+}
+
+// MaxTotalSize returns a function to delete the oldest rotated log files
+// once the log files take more than n bytes.
+func MaxTotalSize(n int64) func(Logger) Logger {
+  return func(l Logger) Logger {
+    l.retention.maxTotal = n
+    return l
+  }
+}
@@ -0,0 +1,109 @@
+package log
+
+import (
+  "fmt"
+  "os"
+  "time"
+)
+
+// RotationPolicy decides when a log file is rotated.
+type RotationPolicy interface {
+  // Next returns the first rotation time strictly after t.
+  Next(t time.Time) time.Time
+}
+
+type calendarRotation struct {
+  every time.Duration
+  loc   *time.Location
+}
+
+// CalendarRotation returns a policy rotating every d on boundaries aligned
+// to the wall clock of loc: whole days rotate at midnight, divisors of a day
+// on the hour, divisors of an hour on the minute. Wall clock alignment keeps
+// the boundaries right across DST changes. Any other d rotates every d of
+// elapsed time. A nil loc means time.Local. It returns nil, meaning no
+// time based rotation, if d is not positive.
+func CalendarRotation(d time.Duration, loc *time.Location) RotationPolicy {
+  if d <= 0 {
+    fmt.Fprintln(os.Stderr, "log: invalid rotation period", d)
+    return nil
+  }
+  if loc == nil {
+    loc = time.Local
+  }
+  return calendarRotation{every: d, loc: loc}
+}
+
+func (c calendarRotation) Next(t time.Time) time.Time {
+  t = t.In(c.loc)
+  year, month, day := t.Date()
+  var next time.Time
+  switch {
+  case c.every%(24*time.Hour) == 0:
+    n := int(c.every / (24 * time.Hour))
+    yday := (t.YearDay()-1)/n*n + n + 1
+    next = time.Date(year, time.January, yday, 0, 0, 0, 0, c.loc)
+    if next.Year() != year {
+      // restart the alignment every year
+      next = time.Date(year+1, time.January, 1, 0, 0, 0, 0, c.loc)
+    }
+  case c.every%time.Hour == 0 && (24*time.Hour)%c.every == 0:
+    n := int(c.every / time.Hour)
+    next = time.Date(year, month, day, t.Hour()/n*n+n, 0, 0, 0, c.loc)
+  case c.every%time.Minute == 0 && time.Hour%c.every == 0:
+    n := int(c.every / time.Minute)
+    next = time.Date(year, month, day, t.Hour(), t.Minute()/n*n+n, 0, 0, c.loc)
+  default:
+    next = t.Truncate(c.every).Add(c.every)
+  }
+  for !next.After(t) {
+    next = next.Add(c.every)
+  }
+  return next
+}
+
+// backupLayout returns the time layout of backup names for a policy, based
+// on the length of its periods. Without a policy backups are named by hour.
+func backupLayout(policy RotationPolicy, now time.Time) string {
+  if policy == nil {
+    return "2006-01-02-15"
+  }
+  next := policy.Next(now)
+  period := policy.Next(next).Sub(next)
+  switch {
+  case period >= 23*time.Hour:
+    return "2006-01-02"
+  case period >= time.Hour:
+    return "2006-01-02-15"
+  case period >= time.Minute:
+    return "2006-01-02-15-04"
+  default:
+    return "2006-01-02-15-04-05"
+  }
+}
+
+// EveryDay sets new log file created every day at local midnight.
+func EveryDay(l Logger) Logger {
+  l.unit = 24 * time.Hour
+  return l
+}
+
+// EveryNHours returns a function to create a new log file every n hours.
+func EveryNHours(n int) func(Logger) Logger {
+  return func(l Logger) Logger {
+    l.unit = time.Duration(n) * time.Hour
+    return l
+  }
+}
+
+// RotateBy returns a function to rotate the log file by policy, taking
+// precedence over EveryHour and friends. A nil policy is ignored.
+func RotateBy(policy RotationPolicy) func(Logger) Logger {
+  return func(l Logger) Logger {
+    if policy == nil {
+      return l
+    }
+    l.rotation = policy
+    return l
+  }
+}
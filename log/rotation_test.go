@@ -0,0 +1,103 @@
+package log
+
+import (
+  "os"
+  "path/filepath"
+  "testing"
+  "time"
+  _ "time/tzdata"
+)
+
+func TestCalendarRotationNext(t *testing.T) {
+  ny, err := time.LoadLocation("America/New_York")
+  if err != nil {
+    t.Fatal(err)
+  }
+  for _, tc := range []struct {
+    every time.Duration
+    from  time.Time
+    want  time.Time
+  }{
+    // midnight to midnight across the end of DST is 25 hours
+    {24 * time.Hour, time.Date(2026, 11, 1, 0, 0, 0, 0, ny), time.Date(2026, 11, 2, 0, 0, 0, 0, ny)},
+    {24 * time.Hour, time.Date(2026, 10, 31, 12, 0, 0, 0, ny), time.Date(2026, 11, 1, 0, 0, 0, 0, ny)},
+    // 2:00 does not exist when DST starts
+    {time.Hour, time.Date(2026, 3, 8, 1, 30, 0, 0, ny), time.Date(2026, 3, 8, 3, 0, 0, 0, ny)},
+    {6 * time.Hour, time.Date(2026, 10, 17, 13, 5, 0, 0, ny), time.Date(2026, 10, 17, 18, 0, 0, 0, ny)},
+    {15 * time.Minute, time.Date(2026, 10, 17, 13, 5, 0, 0, ny), time.Date(2026, 10, 17, 13, 15, 0, 0, ny)},
+    {time.Minute, time.Date(2026, 10, 17, 23, 59, 30, 0, ny), time.Date(2026, 10, 18, 0, 0, 0, 0, ny)},
+  } {
+    got := CalendarRotation(tc.every, ny).Next(tc.from)
+    if !got.Equal(tc.want) {
+      t.Errorf("Next(%v) every %v = %v, want %v", tc.from, tc.every, got, tc.want)
+    }
+  }
+  if d := CalendarRotation(24*time.Hour, ny).Next(time.Date(2026, 11, 1, 0, 0, 0, 0, ny)).Sub(
+    time.Date(2026, 11, 1, 0, 0, 0, 0, ny)); d != 25*time.Hour {
+    t.Errorf("day across DST lasts %v", d)
+  }
+}
+
+func TestSegmentTimeRotation(t *testing.T) {
+  dir := t.TempDir()
+  clock := &fakeClock{t: time.Date(2024, 3, 1, 12, 0, 0, 10e6, time.Local)}
+  inst := NewLogInstance(LogFilePath(dir, "app.log"), RotateBy(CalendarRotation(50*time.Millisecond, nil)),
+    LogClock(clock.Now))
+  l := NewAdaptorFromInstance(inst, 3)
+  l.Infoln("first")
+  clock.Add(60 * time.Millisecond)
+  l.Infoln("second")
+  inst.Stop()
+
+  backups, _ := filepath.Glob(filepath.Join(dir, "app.*.log"))
+  if len(backups) != 1 {
+    t.Fatalf("got backups %q", backups)
+  }
+  if data, _ := os.ReadFile(filepath.Join(dir, "app.log")); len(data) == 0 {
+    t.Error("current file is empty")
+  }
+}
+
+// stuckRotation advances until stuck is set, then returns t itself.
+type stuckRotation struct{ stuck *bool }
+
+func (s stuckRotation) Next(t time.Time) time.Time {
+  if *s.stuck {
+    return t
+  }
+  return t.Truncate(time.Minute).Add(time.Minute)
+}
+
+func TestBadRotationPolicy(t *testing.T) {
+  for _, d := range []time.Duration{0, -time.Hour} {
+    if p := CalendarRotation(d, nil); p != nil {
+      t.Errorf("CalendarRotation(%v) = %v", d, p)
+    }
+  }
+
+  dir := t.TempDir()
+  stuck := false
+  clock := &fakeClock{t: time.Date(2024, 3, 1, 12, 0, 30, 0, time.UTC)}
+  inst := NewLogInstance(LogFilePath(dir, "app.log"), RotateBy(stuckRotation{&stuck}),
+    LogClock(clock.Now))
+  l := NewAdaptorFromInstance(inst, 3)
+  l.Infoln("first")
+  stuck = true
+  clock.Add(time.Minute)
+  done := make(chan struct{})
+  go func() {
+    l.Infoln("second")
+    l.Infoln("third")
+    close(done)
+  }()
+  select {
+  case <-done:
+  case <-time.After(5 * time.Second):
+    t.Fatal("write hangs on a policy not moving forward")
+  }
+  inst.Stop()
+
+  if backups, _ := filepath.Glob(filepath.Join(dir, "app.*.log")); len(backups) != 1 {
+    t.Fatalf("got backups %q", backups)
+  }
+}
@@ -0,0 +1,168 @@
+package log
+
+import (
+  "fmt"
+  "os"
+  "path"
+  "runtime"
+  "strconv"
+  "sync"
+  "time"
+)
+
+// samplingConfig holds the settings of the Sample decorators.
+type samplingConfig struct {
+  first      int
+  thereafter int
+  interval   time.Duration
+  bypass     LogLevel
+  bypassSet  bool // bypass defaults to FATAL
+}
+
+// maxSampleKeys bounds the messages counted apart in an interval, the
+// records of the other messages are counted per call site.
+const maxSampleKeys = 1000
+
+// Sample returns a function to sample repeated records: in every interval,
+// the first records of a call site and message are logged, then every
+// thereafter-th one. The number of suppressed records is reported at the end
+// of the interval. A negative first leaves sampling off.
+func Sample(first, thereafter int, interval time.Duration) func(Logger) Logger {
+  return func(l Logger) Logger {
+    if first < 0 {
+      fmt.Fprintln(os.Stderr, "log: sample: first must not be negative")
+      return l
+    }
+    l.sampling.first = first
+    l.sampling.thereafter = thereafter
+    l.sampling.interval = interval
+    return l
+  }
+}
+
+// SampleBypass returns a function to let the records at or above level
+// bypass sampling. FATAL records are never sampled.
+func SampleBypass(level LogLevel) func(Logger) Logger {
+  return func(l Logger) Logger {
+    l.sampling.bypass = level
+    l.sampling.bypassSet = true
+    return l
+  }
+}
+
+type sampleKey struct {
+  pc  uintptr
+  msg string
+}
+
+type sampleCount struct {
+  n          uint64
+  suppressed uint64
+  level      LogLevel
+  caller     string
+}
+
+// sampler counts records per call site and message during an interval.
+type sampler struct {
+  conf   samplingConfig
+  mu     sync.Mutex
+  counts map[sampleKey]*sampleCount
+  done   chan struct{}
+  once   sync.Once
+  wg     sync.WaitGroup
+}
+
+func newSampler(conf samplingConfig, l *Logger) *sampler {
+  if !conf.bypassSet {
+    conf.bypass = FATAL
+  }
+  s := &sampler{
+    conf:   conf,
+    counts: make(map[sampleKey]*sampleCount),
+    done:   make(chan struct{}),
+  }
+  s.wg.Add(1)
+  go s.run(l)
+  return s
+}
+
+// allow reports whether the record of msg logged callDepth frames up should
+// be written.
+func (s *sampler) allow(callDepth int, level LogLevel, msg string) bool {
+  if level >= s.conf.bypass {
+    return true
+  }
+  pc, file, line, _ := runtime.Caller(callDepth)
+  return s.allowAt(pc, file, line, level, msg)
+}
+
+// allowAt is allow for the call site at pc, file and line.
+func (s *sampler) allowAt(pc uintptr, file string, line int, level LogLevel, msg string) bool {
+  if level >= s.conf.bypass {
+    return true
+  }
+  key := sampleKey{pc: pc, msg: msg}
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  c := s.counts[key]
+  if c == nil && len(s.counts) >= maxSampleKeys {
+    key.msg = ""
+    c = s.counts[key]
+  }
+  if c == nil {
+    c = &sampleCount{caller: path.Base(file) + ":" + strconv.Itoa(line)}
+    s.counts[key] = c
+  }
+  c.n++
+  if c.n <= uint64(s.conf.first) ||
+    (s.conf.thereafter > 0 && (c.n-uint64(s.conf.first))%uint64(s.conf.thereafter) == 0) {
+    return true
+  }
+  c.suppressed++
+  if level > c.level {
+    c.level = level
+  }
+  return false
+}
+
+func (s *sampler) run(l *Logger) {
+  defer s.wg.Done()
+  ticker := time.NewTicker(s.conf.interval)
+  defer ticker.Stop()
+  for {
+    select {
+    case <-ticker.C:
+      s.report(l)
+    case <-s.done:
+      s.report(l)
+      return
+    }
+  }
+}
+
+// report writes a summary of the records suppressed during the interval and
+// starts a new one.
+func (s *sampler) report(l *Logger) {
+  s.mu.Lock()
+  counts := s.counts
+  s.counts = make(map[sampleKey]*sampleCount)
+  s.mu.Unlock()
+  for key, c := range counts {
+    if c.suppressed == 0 {
+      continue
+    }
+    l.dispatch(&Record{
+      Time:    l.now(),
+      Level:   c.level,
+      Message: fmt.Sprintf("suppressed %d similar messages", c.suppressed),
+      Fields:  []Field{String("caller", c.caller), String("sample", key.msg)},
+    })
+  }
+}
+
+func (s *sampler) stop() {
+  s.once.Do(func() {
+    close(s.done)
+  })
+  s.wg.Wait()
+}
@@ -0,0 +1,69 @@
+package log
+
+import (
+  "strings"
+  "testing"
+  "time"
+)
+
+func TestSampling(t *testing.T) {
+  sink := &gateSink{gate: make(chan struct{})}
+  close(sink.gate)
+  inst := NewLogInstance(LogSink(sink, TRACE), Sample(2, 10, time.Hour), SampleBypass(ERROR))
+  l := NewAdaptorFromInstance(inst, 3)
+  for i := 0; i < 25; i++ {
+    l.Warnf("hot loop %d", i)
+    l.Errorf("always %d", i)
+  }
+  inst.Stop()
+
+  var warns, errs int
+  var summary string
+  for _, msg := range sink.msgs {
+    switch {
+    case strings.HasPrefix(msg, "hot loop"):
+      warns++
+    case strings.HasPrefix(msg, "always"):
+      errs++
+    case strings.HasPrefix(msg, "suppressed"):
+      summary = msg
+    }
+  }
+  // 1st, 2nd, 12th and 22nd
+  if warns != 4 || errs != 25 {
+    t.Errorf("got %d warnings and %d errors", warns, errs)
+  }
+  if summary != "suppressed 21 similar messages" {
+    t.Errorf("got summary %q", summary)
+  }
+}
+
+func TestSamplingOptions(t *testing.T) {
+  // TRACE set before Sample is kept: nothing is sampled
+  inst := NewLogInstance(LogSink(&gateSink{}, TRACE), SampleBypass(TRACE), Sample(1, 0, time.Hour))
+  if inst.state.sampler.conf.bypass != TRACE {
+    t.Errorf("bypass %v", inst.state.sampler.conf.bypass)
+  }
+  inst.Stop()
+  inst = NewLogInstance(LogSink(&gateSink{}, TRACE), Sample(-1, 0, time.Hour))
+  if inst.state.sampler != nil {
+    t.Error("negative first accepted")
+  }
+  inst.Stop()
+
+  // high cardinality messages are counted per call site past the limit
+  sink := &gateSink{gate: make(chan struct{})}
+  close(sink.gate)
+  inst = NewLogInstance(LogSink(sink, TRACE), Sample(1, 0, time.Hour))
+  l := NewAdaptorFromInstance(inst, 3)
+  for i := 0; i < 3*maxSampleKeys; i++ {
+    l.Infoln("item", i)
+  }
+  if n := len(inst.state.sampler.counts); n > maxSampleKeys+1 {
+    t.Errorf("%d keys counted", n)
+  }
+  inst.Stop()
+  if n := len(sink.msgs); n != maxSampleKeys+2 {
+    t.Errorf("got %d records", n)
+  }
+}
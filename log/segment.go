@@ -0,0 +1,267 @@
+package log
+
+import (
+  "fmt"
+  "os"
+  "path"
+  "strconv"
+  "strings"
+  "sync"
+  "time"
+)
+
+// logSegment implements io.Writer
+type logSegment struct {
+  mu          sync.Mutex
+  policy      RotationPolicy
+  layout      string
+  maxSize     int64
+  size        int64
+  logPath     string
+  logFileName string
+  logFile     *os.File
+  pid         int
+  period      time.Time // start of the period covered by logFile
+  next        time.Time // next rotation time, zero without a policy
+  retention   retention
+  compressor  Compressor
+  bgMu        sync.Mutex
+  bg          sync.WaitGroup
+  now         func() time.Time
+  lastStamp   string // period and sequence number of the last backup
+  lastSeq     int
+}
+
+func newLogSegment(conf Logger) *logSegment {
+  now := conf.now()
+  logPath := conf.logPath
+  if logPath != "" {
+    err := os.MkdirAll(logPath, os.ModePerm)
+    if err != nil {
+      fmt.Fprintln(os.Stderr, err)
+      return nil
+    }
+    name := strings.TrimSpace(conf.name)
+    if name == "" {
+      name = getLogName()
+    }
+    filename := path.Join(logPath, name)
+    logFile, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+    if err != nil {
+      if os.IsNotExist(err) {
+        logFile, err = os.Create(path.Join(logPath, name))
+        if err != nil {
+          fmt.Fprintln(os.Stderr, err)
+          return nil
+        }
+      } else {
+        fmt.Fprintln(os.Stderr, err)
+        return nil
+      }
+    }
+    var size int64
+    if info, err := logFile.Stat(); err == nil {
+      size = info.Size()
+    }
+    policy := conf.rotation
+    if policy == nil && conf.unit > 0 {
+      loc := time.Local
+      if conf.utc {
+        loc = time.UTC
+      }
+      policy = CalendarRotation(conf.unit, loc)
+    }
+    if policy != nil && !policy.Next(now).After(now) {
+      fmt.Fprintln(os.Stderr, "log: rotation policy returned no time after", now)
+      policy = nil
+    }
+    ls := &logSegment{
+      policy:      policy,
+      layout:      backupLayout(policy, now),
+      maxSize:     conf.maxSize,
+      retention:   conf.retention,
+      compressor:  conf.compressor,
+      size:        size,
+      logPath:     logPath,
+      logFileName: filename,
+      logFile:     logFile,
+      pid:         os.Getpid(),
+      period:      now,
+      now:         conf.now,
+    }
+    if policy != nil {
+      ls.next = policy.Next(now)
+      ls.period = now.In(ls.next.Location())
+    }
+    return ls
+  }
+  return nil
+}
+
+func (ls *logSegment) Write(p []byte) (n int, err error) {
+  ls.mu.Lock()
+  defer ls.mu.Unlock()
+  if ls.logFile != os.Stdout && ls.logFile != os.Stderr {
+    now := ls.now()
+    if ls.policy != nil && !now.Before(ls.next) {
+      // a failed rotation is retried at the next boundary
+      ls.rotate(ls.period)
+      // the file starts with the last boundary passed, stopping at a time
+      // not after the previous one as a broken policy would loop forever
+      ls.period = ls.next
+      for b := ls.policy.Next(ls.period); b.After(ls.period) && !now.Before(b); b = ls.policy.Next(b) {
+        ls.period = b
+      }
+      ls.next = ls.policy.Next(now)
+      if !ls.next.After(now) {
+        fmt.Fprintln(os.Stderr, "log: rotation policy returned no time after", now)
+        ls.policy, ls.next = nil, time.Time{}
+      }
+    }
+    if ls.maxSize > 0 && ls.size > 0 && ls.size+int64(len(p)) > ls.maxSize {
+      label := ls.period
+      if ls.policy == nil {
+        label = now
+      }
+      ls.rotate(label)
+    }
+  }
+  n, err = ls.logFile.Write(p)
+  ls.size += int64(n)
+  return
+}
+
+// rotate renames the current file to a backup named after t and starts a
+// new one. If the file can't be renamed it goes on appending to it. It
+// falls back to stderr if no file can be opened.
+func (ls *logSegment) rotate(t time.Time) {
+  ls.logFile.Close()
+  ls.logFile = nil
+  backup := ls.backupName(t)
+  if err := os.Rename(ls.logFileName, backup); err != nil {
+    fmt.Fprintln(os.Stderr, err)
+    ls.logFile, err = os.OpenFile(ls.logFileName, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+    if err != nil {
+      fmt.Fprintln(os.Stderr, err)
+      ls.logFile = os.Stderr
+    }
+    return
+  }
+
+  var err error
+  ls.size = 0
+  ls.logFile, err = os.Create(ls.logFileName)
+  if err != nil {
+    // log into stderr if we can't create new file
+    fmt.Fprintln(os.Stderr, err)
+    ls.logFile = os.Stderr
+    return
+  }
+  ls.afterRotate(backup)
+}
+
+// afterRotate compresses the backup just made and removes the backups
+// exceeding the retention limits in the background. Background jobs never
+// run concurrently and Close waits for them.
+func (ls *logSegment) afterRotate(backup string) {
+  compress := ls.compressor != nil
+  if !compress && !ls.retention.enabled() {
+    return
+  }
+  ls.bg.Add(1)
+  go func() {
+    defer ls.bg.Done()
+    ls.bgMu.Lock()
+    defer ls.bgMu.Unlock()
+    if compress {
+      ls.compress(backup)
+    }
+    if ls.retention.enabled() {
+      // ages are compared to the file times set by the system clock
+      ls.cleanup(time.Now())
+    }
+  }()
+}
+
+// backupName returns a free name for a backup of the period starting at t,
+// e.g. "app.2026-10-17-13.log". Size rotated backups always carry a sequence
+// number ("app.2026-10-17-13.1.log"), time rotated ones only to avoid a
+// collision. Sequence numbers only grow within a period, even after the
+// retention deleted the first backups, so they order the backups.
+func (ls *logSegment) backupName(t time.Time) string {
+  base := path.Base(ls.logFileName)
+  ext := path.Ext(base)
+  stamp := t.Format(ls.layout)
+  prefix := path.Join(ls.logPath, strings.TrimSuffix(base, ext)+"."+stamp)
+  if ls.maxSize <= 0 {
+    name := prefix + ext
+    if !fileExists(name) && (ls.compressor == nil || !fileExists(name+ls.compressor.Ext())) {
+      return name
+    }
+  }
+  seq := 0
+  if stamp == ls.lastStamp {
+    seq = ls.lastSeq
+  }
+  if entries, err := os.ReadDir(ls.logPath); err == nil {
+    pattern := backupPattern(base, ls.compressor)
+    for _, entry := range entries {
+      if m := pattern.FindStringSubmatch(entry.Name()); m != nil && m[1] == stamp {
+        if n, _ := strconv.Atoi(m[2]); n > seq {
+          seq = n
+        }
+      }
+    }
+  }
+  seq++
+  ls.lastStamp, ls.lastSeq = stamp, seq
+  return prefix + "." + strconv.Itoa(seq) + ext
+}
+
+func fileExists(name string) bool {
+  _, err := os.Stat(name)
+  return !os.IsNotExist(err)
+}
+
+// Reopen closes the log file and opens logFileName again, so that a file
+// moved away by an external tool is replaced by a new one.
+func (ls *logSegment) Reopen() error {
+  ls.mu.Lock()
+  defer ls.mu.Unlock()
+  logFile, err := os.OpenFile(ls.logFileName, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+  if err != nil {
+    // keep writing to the current file
+    return err
+  }
+  if ls.logFile != os.Stdout && ls.logFile != os.Stderr {
+    ls.logFile.Close()
+  }
+  ls.logFile = logFile
+  ls.size = 0
+  if info, err := logFile.Stat(); err == nil {
+    ls.size = info.Size()
+  }
+  return nil
+}
+
+func (ls *logSegment) Close() {
+  ls.mu.Lock()
+  if ls.logFile != os.Stdout && ls.logFile != os.Stderr {
+    ls.logFile.Close()
+  }
+  ls.mu.Unlock()
+  ls.bg.Wait()
+}
+
+func getLogName() string {
+  return path.Base(os.Args[0]) + ".log"
+}
+
+// MaxSize returns a function to rotate the log file once it would exceed n
+// bytes. It can be combined with time based rotation.
+func MaxSize(n int64) func(Logger) Logger {
+  return func(l Logger) Logger {
+    l.maxSize = n
+    return l
+  }
+}
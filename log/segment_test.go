@@ -0,0 +1,203 @@
+package log
+
+import (
+  "compress/gzip"
+  "errors"
+  "fmt"
+  "io"
+  "os"
+  "path/filepath"
+  "sort"
+  "strings"
+  "testing"
+  "time"
+)
+
+func TestSegmentMaxSize(t *testing.T) {
+  dir := t.TempDir()
+  inst := NewLogInstance(LogFilePath(dir, "app.log"), MaxSize(200))
+  l := NewAdaptorFromInstance(inst, 3)
+  for i := 0; i < 10; i++ {
+    l.Infof("%s", strings.Repeat("x", 60))
+  }
+  inst.Stop()
+
+  backups, _ := filepath.Glob(filepath.Join(dir, "app.*.*.log"))
+  if len(backups) != 4 {
+    t.Fatalf("got backups %q", backups)
+  }
+  for i, want := range []string{".1.log", ".2.log", ".3.log", ".4.log"} {
+    if !strings.HasSuffix(backups[i], want) {
+      t.Errorf("backup %q, want suffix %q", backups[i], want)
+    }
+    info, err := os.Stat(backups[i])
+    if err != nil || info.Size() > 200 {
+      t.Errorf("backup %q: %v %v", backups[i], info, err)
+    }
+  }
+}
+
+func TestSegmentRetention(t *testing.T) {
+  dir := t.TempDir()
+  // files not matching the backup pattern are left alone
+  for _, name := range []string{"other.log", "app.notes.log", "app.2026-01-01.log.tmp"} {
+    os.WriteFile(filepath.Join(dir, name), []byte("keep"), 0666)
+  }
+  inst := NewLogInstance(LogFilePath(dir, "app.log"), MaxSize(100), MaxBackups(2))
+  l := NewAdaptorFromInstance(inst, 3)
+  for i := 0; i < 10; i++ {
+    l.Infof("%s", strings.Repeat("x", 60))
+  }
+  inst.Stop()
+
+  backups, _ := filepath.Glob(filepath.Join(dir, "app.*.*.log"))
+  if len(backups) != 2 || !strings.HasSuffix(backups[0], ".8.log") || !strings.HasSuffix(backups[1], ".9.log") {
+    t.Errorf("got backups %q", backups)
+  }
+  for _, name := range []string{"other.log", "app.notes.log", "app.2026-01-01.log.tmp"} {
+    if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+      t.Error(err)
+    }
+  }
+}
+
+func TestSegmentBackupSequence(t *testing.T) {
+  dir := t.TempDir()
+  now := time.Date(2026, 10, 17, 13, 0, 0, 0, time.Local)
+  // .1 was deleted by the retention, .9 and .10 share their mtime
+  for _, seq := range []string{"2", "9", "10"} {
+    name := filepath.Join(dir, "app.2026-10-17-13."+seq+".log")
+    os.WriteFile(name, []byte(seq), 0666)
+    os.Chtimes(name, now, now)
+  }
+  inst := NewLogInstance(LogFilePath(dir, "app.log"), MaxSize(100), MaxBackups(2),
+    LogClock(func() time.Time { return now }))
+  l := NewAdaptorFromInstance(inst, 3)
+  for i := 0; i < 3; i++ {
+    l.Infof("%s", strings.Repeat("x", 60))
+  }
+  inst.Stop()
+
+  backups, _ := filepath.Glob(filepath.Join(dir, "app.*.*.log"))
+  sort.Strings(backups)
+  if len(backups) != 2 || !strings.HasSuffix(backups[0], ".11.log") || !strings.HasSuffix(backups[1], ".12.log") {
+    t.Errorf("got backups %q", backups)
+  }
+}
+
+type failingCompressor struct{}
+
+func (failingCompressor) Ext() string {
+  return ".bad"
+}
+
+func (failingCompressor) Compress(dst io.Writer, src io.Reader) error {
+  dst.Write([]byte("partial"))
+  return errors.New("no space left")
+}
+
+func TestSegmentCompress(t *testing.T) {
+  dir := t.TempDir()
+  inst := NewLogInstance(LogFilePath(dir, "app.log"), MaxSize(100), Compress(GzipCompressor))
+  l := NewAdaptorFromInstance(inst, 3)
+  for i := 0; i < 3; i++ {
+    l.Infof("line %d %s", i, strings.Repeat("x", 60))
+  }
+  inst.Stop()
+
+  backups, _ := filepath.Glob(filepath.Join(dir, "app.*.*"))
+  if len(backups) != 2 || !strings.HasSuffix(backups[0], ".1.log.gz") {
+    t.Fatalf("got backups %q", backups)
+  }
+  f, err := os.Open(backups[0])
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer f.Close()
+  zr, err := gzip.NewReader(f)
+  if err != nil {
+    t.Fatal(err)
+  }
+  data, _ := io.ReadAll(zr)
+  if !strings.Contains(string(data), "line 0 ") {
+    t.Errorf("unexpected content %q", data)
+  }
+
+  dir = t.TempDir()
+  inst = NewLogInstance(LogFilePath(dir, "app.log"), MaxSize(100), Compress(failingCompressor{}))
+  l = NewAdaptorFromInstance(inst, 3)
+  for i := 0; i < 2; i++ {
+    l.Infof("line %d %s", i, strings.Repeat("x", 60))
+  }
+  inst.Stop()
+  backups, _ = filepath.Glob(filepath.Join(dir, "app.*.*"))
+  if len(backups) != 1 || !strings.HasSuffix(backups[0], ".1.log") {
+    t.Errorf("got backups %q", backups)
+  }
+}
+
+func TestSegmentReopen(t *testing.T) {
+  dir := t.TempDir()
+  name := filepath.Join(dir, "app.log")
+  inst := NewLogInstance(LogFilePath(dir, "app.log"))
+  l := NewAdaptorFromInstance(inst, 3)
+  l.Infoln("before")
+  // what logrotate does in create mode
+  if err := os.Rename(name, name+".1"); err != nil {
+    t.Fatal(err)
+  }
+  l.Infoln("still old")
+  if err := l.Reopen(); err != nil {
+    t.Fatal(err)
+  }
+  l.Infoln("after")
+  inst.Stop()
+
+  old, _ := os.ReadFile(name + ".1")
+  cur, _ := os.ReadFile(name)
+  if !strings.Contains(string(old), "before") || !strings.Contains(string(old), "still old") ||
+    strings.Contains(string(old), "after") {
+    t.Errorf("old file %q", old)
+  }
+  if !strings.Contains(string(cur), "after") || strings.Contains(string(cur), "before") {
+    t.Errorf("new file %q", cur)
+  }
+}
+
+func TestSegmentRenameFailure(t *testing.T) {
+  dir := t.TempDir()
+  // the backup names exceed the maximum file name length
+  name := strings.Repeat("a", 245) + ".log"
+  inst := NewLogInstance(LogFilePath(dir, name), MaxSize(100))
+  l := NewAdaptorFromInstance(inst, 3)
+  for i := 0; i < 3; i++ {
+    l.Infof("line %d %s", i, strings.Repeat("x", 60))
+  }
+  inst.Stop()
+
+  data, err := os.ReadFile(filepath.Join(dir, name))
+  if err != nil {
+    t.Fatal(err)
+  }
+  for i := 0; i < 3; i++ {
+    if !strings.Contains(string(data), fmt.Sprintf("line %d ", i)) {
+      t.Errorf("line %d lost in %q", i, data)
+    }
+  }
+}
+
+func TestSegmentMaxAgeFakeClock(t *testing.T) {
+  dir := t.TempDir()
+  clock := &fakeClock{t: time.Now().Add(365 * 24 * time.Hour)}
+  inst := NewLogInstance(LogFilePath(dir, "app.log"), MaxSize(100), MaxAge(time.Hour), LogClock(clock.Now))
+  l := NewAdaptorFromInstance(inst, 3)
+  for i := 0; i < 3; i++ {
+    l.Infof("%s", strings.Repeat("x", 60))
+  }
+  inst.Stop()
+
+  // the backups were just written, whatever the logger clock says
+  if backups, _ := filepath.Glob(filepath.Join(dir, "app.*.*.log")); len(backups) != 2 {
+    t.Errorf("got backups %q", backups)
+  }
+}
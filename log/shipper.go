@@ -0,0 +1,432 @@
+package log
+
+import (
+  "bufio"
+  "bytes"
+  "errors"
+  "fmt"
+  "io"
+  "net"
+  "net/http"
+  "net/url"
+  "os"
+  "path"
+  "sync"
+  "sync/atomic"
+  "time"
+)
+
+// ShipperConfig configures a ShipperSink.
+type ShipperConfig struct {
+  // URL of the collector, "tcp://host:port" for newline delimited records
+  // over TCP, or an http(s) URL receiving them in POST requests.
+  URL string
+  // Encoder defaults to JSONEncoder. It must produce single line records.
+  Encoder Encoder
+  // BatchSize (default 100) records are sent at once, or fewer every
+  // FlushInterval (default 1s).
+  BatchSize     int
+  FlushInterval time.Duration
+  // QueueSize (default 10000) records wait in memory to be shipped, the
+  // records logged while it is full are dropped.
+  QueueSize int
+  // A batch is retried MaxRetries (default 3) times, waiting from
+  // MinBackoff (default 100ms) doubling up to MaxBackoff (default 30s).
+  MaxRetries int
+  MinBackoff time.Duration
+  MaxBackoff time.Duration
+  // Timeout of a single send, default 10s.
+  Timeout time.Duration
+  // CloseTimeout (default 5s) bounds the time Close spends shipping the
+  // queued records, the others are spilled or dropped.
+  CloseTimeout time.Duration
+  // SpillDir keeps the batches which could not be sent in a file, replayed
+  // in order once the collector is back. Without it they are dropped. The
+  // directory must not be shared between sinks.
+  SpillDir string
+  // MaxSpillSize (default 100MB) bounds the size of the spill file, the
+  // batches not fitting in are dropped.
+  MaxSpillSize int64
+}
+
+// ShipperStats counts the records handled by a ShipperSink.
+type ShipperStats struct {
+  Sent    uint64 // delivered to the collector
+  Failed  uint64 // dropped
+  Spilled uint64 // written to the spill file
+}
+
+// ShipperSink ships batches of encoded records to a remote collector.
+type ShipperSink struct {
+  conf      ShipperConfig
+  url       *url.URL
+  client    *http.Client
+  conn      net.Conn
+  spillName string
+  queue     chan []byte
+  done      chan struct{}
+  stopped   chan struct{}
+  closeMu   sync.RWMutex
+  closing   bool // set by Close, no record is queued afterwards
+
+  // replay state of the spill file, used by the shipping goroutine only
+  spilled    bool
+  backoff    time.Duration
+  nextReplay time.Time
+  deadline   time.Time // of the shipping at Close
+
+  sent         uint64
+  failed       uint64
+  spilledCount uint64
+}
+
+// NewShipperSink returns a sink shipping records to conf.URL from a
+// background goroutine.
+func NewShipperSink(conf ShipperConfig) (*ShipperSink, error) {
+  u, err := url.Parse(conf.URL)
+  if err != nil {
+    return nil, err
+  }
+  switch u.Scheme {
+  case "tcp", "http", "https":
+  default:
+    return nil, fmt.Errorf("log: unsupported shipper url %q", conf.URL)
+  }
+  if conf.Encoder == nil {
+    conf.Encoder = JSONEncoder
+  }
+  if conf.BatchSize <= 0 {
+    conf.BatchSize = 100
+  }
+  if conf.FlushInterval <= 0 {
+    conf.FlushInterval = time.Second
+  }
+  if conf.QueueSize <= 0 {
+    conf.QueueSize = 10000
+  }
+  if conf.MaxRetries < 0 {
+    conf.MaxRetries = 0
+  } else if conf.MaxRetries == 0 {
+    conf.MaxRetries = 3
+  }
+  if conf.MinBackoff <= 0 {
+    conf.MinBackoff = 100 * time.Millisecond
+  }
+  if conf.MaxBackoff < conf.MinBackoff {
+    conf.MaxBackoff = 30 * time.Second
+  }
+  if conf.Timeout <= 0 {
+    conf.Timeout = 10 * time.Second
+  }
+  if conf.CloseTimeout <= 0 {
+    conf.CloseTimeout = 5 * time.Second
+  }
+  if conf.MaxSpillSize <= 0 {
+    conf.MaxSpillSize = 100 << 20
+  }
+  s := &ShipperSink{
+    conf:    conf,
+    url:     u,
+    client:  &http.Client{Timeout: conf.Timeout},
+    queue:   make(chan []byte, conf.QueueSize),
+    done:    make(chan struct{}),
+    stopped: make(chan struct{}),
+    backoff: conf.MinBackoff,
+  }
+  if conf.SpillDir != "" {
+    if err := os.MkdirAll(conf.SpillDir, os.ModePerm); err != nil {
+      return nil, err
+    }
+    s.spillName = path.Join(conf.SpillDir, "spill.ndjson")
+    // left over by a previous run
+    if info, err := os.Stat(s.spillName); err == nil && info.Size() > 0 {
+      s.spilled = true
+    }
+  }
+  go s.run()
+  return s, nil
+}
+
+// WriteRecord encodes r and queues it for shipping. It never blocks.
+func (s *ShipperSink) WriteRecord(r *Record) error {
+  buf := getBuffer()
+  s.conf.Encoder.Encode(buf, r)
+  line := make([]byte, buf.Len())
+  copy(line, buf.Bytes())
+  putBuffer(buf)
+  s.closeMu.RLock()
+  defer s.closeMu.RUnlock()
+  if s.closing {
+    atomic.AddUint64(&s.failed, 1)
+    return errors.New("log: shipper closed")
+  }
+  select {
+  case s.queue <- line:
+    return nil
+  default:
+    atomic.AddUint64(&s.failed, 1)
+    return errors.New("log: shipper queue full")
+  }
+}
+
+// Stats returns the record counters.
+func (s *ShipperSink) Stats() ShipperStats {
+  return ShipperStats{
+    Sent:    atomic.LoadUint64(&s.sent),
+    Failed:  atomic.LoadUint64(&s.failed),
+    Spilled: atomic.LoadUint64(&s.spilledCount),
+  }
+}
+
+// Close ships the queued records and stops the sink, giving up after
+// CloseTimeout.
+func (s *ShipperSink) Close() error {
+  s.closeMu.Lock()
+  if !s.closing {
+    s.closing = true
+    close(s.done)
+  }
+  s.closeMu.Unlock()
+  timer := time.NewTimer(s.conf.CloseTimeout)
+  defer timer.Stop()
+  select {
+  case <-s.stopped:
+    return nil
+  case <-timer.C:
+    return errors.New("log: shipper close timed out")
+  }
+}
+
+func (s *ShipperSink) run() {
+  defer close(s.stopped)
+  ticker := time.NewTicker(s.conf.FlushInterval)
+  defer ticker.Stop()
+  batch := make([][]byte, 0, s.conf.BatchSize)
+  for {
+    select {
+    case line := <-s.queue:
+      batch = append(batch, line)
+      if len(batch) >= s.conf.BatchSize {
+        s.ship(batch)
+        batch = batch[:0]
+      }
+    case <-ticker.C:
+      s.ship(batch)
+      batch = batch[:0]
+    case <-s.done:
+      s.deadline = time.Now().Add(s.conf.CloseTimeout)
+      for len(s.queue) > 0 {
+        batch = append(batch, <-s.queue)
+        if len(batch) >= s.conf.BatchSize {
+          s.ship(batch)
+          batch = batch[:0]
+        }
+      }
+      // last chance for the spilled records
+      s.nextReplay = time.Time{}
+      s.ship(batch)
+      if s.conn != nil {
+        s.conn.Close()
+      }
+      return
+    }
+  }
+}
+
+// ship sends batch after the spilled records, keeping their order.
+func (s *ShipperSink) ship(batch [][]byte) {
+  if s.pastDeadline() {
+    s.spill(batch)
+    return
+  }
+  if s.spilled {
+    if time.Now().Before(s.nextReplay) || !s.replay() {
+      s.spill(batch)
+      return
+    }
+  }
+  if len(batch) == 0 {
+    return
+  }
+  var err error
+  backoff := s.conf.MinBackoff
+  for attempt := 0; attempt <= s.conf.MaxRetries; attempt++ {
+    if attempt > 0 {
+      if s.pastDeadline() {
+        break
+      }
+      time.Sleep(backoff)
+      backoff *= 2
+      if backoff > s.conf.MaxBackoff {
+        backoff = s.conf.MaxBackoff
+      }
+    }
+    if err = s.send(batch); err == nil {
+      atomic.AddUint64(&s.sent, uint64(len(batch)))
+      return
+    }
+    if s.drop(batch, err) {
+      return
+    }
+  }
+  fmt.Fprintf(os.Stderr, "log: can't ship %d records: %v\n", len(batch), err)
+  s.backoff = s.conf.MinBackoff
+  s.nextReplay = time.Now().Add(s.backoff)
+  s.spill(batch)
+}
+
+// rejectedError is returned by send when the collector refuses a batch,
+// which is never retried.
+type rejectedError struct {
+  status string
+}
+
+func (e rejectedError) Error() string {
+  return "log: collector rejected records: " + e.status
+}
+
+// drop drops batch and reports true if err says it is refused for good.
+func (s *ShipperSink) drop(batch [][]byte, err error) bool {
+  var rejected rejectedError
+  if !errors.As(err, &rejected) {
+    return false
+  }
+  fmt.Fprintf(os.Stderr, "log: dropped %d records: %v\n", len(batch), err)
+  atomic.AddUint64(&s.failed, uint64(len(batch)))
+  return true
+}
+
+// pastDeadline reports whether Close ran out of time.
+func (s *ShipperSink) pastDeadline() bool {
+  return !s.deadline.IsZero() && !time.Now().Before(s.deadline)
+}
+
+// spill appends batch to the spill file, or drops it without one.
+func (s *ShipperSink) spill(batch [][]byte) {
+  if len(batch) == 0 {
+    return
+  }
+  if s.spillName == "" {
+    atomic.AddUint64(&s.failed, uint64(len(batch)))
+    return
+  }
+  size := int64(0)
+  for _, line := range batch {
+    size += int64(len(line))
+  }
+  if info, err := os.Stat(s.spillName); err == nil && info.Size()+size > s.conf.MaxSpillSize {
+    fmt.Fprintf(os.Stderr, "log: spill file full, dropped %d records\n", len(batch))
+    atomic.AddUint64(&s.failed, uint64(len(batch)))
+    return
+  }
+  f, err := os.OpenFile(s.spillName, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+  if err == nil {
+    _, err = f.Write(bytes.Join(batch, nil))
+    if cerr := f.Close(); err == nil {
+      err = cerr
+    }
+  }
+  if err != nil {
+    fmt.Fprintln(os.Stderr, err)
+    atomic.AddUint64(&s.failed, uint64(len(batch)))
+    return
+  }
+  s.spilled = true
+  atomic.AddUint64(&s.spilledCount, uint64(len(batch)))
+}
+
+// replay sends the spilled records in batches and reports whether all of
+// them were delivered. The records left are kept in the spill file.
+func (s *ShipperSink) replay() bool {
+  f, err := os.Open(s.spillName)
+  if err != nil {
+    s.spilled = !os.IsNotExist(err)
+    return !s.spilled
+  }
+  r := bufio.NewReader(f)
+  batch := make([][]byte, 0, s.conf.BatchSize)
+  var sendErr error
+  for sendErr == nil {
+    if s.pastDeadline() {
+      sendErr = errors.New("log: shipper close timed out")
+      break
+    }
+    line, err := r.ReadBytes('\n')
+    if len(line) > 0 {
+      batch = append(batch, line)
+    }
+    if len(batch) == s.conf.BatchSize || (err != nil && len(batch) > 0) {
+      if sendErr = s.send(batch); sendErr == nil {
+        atomic.AddUint64(&s.sent, uint64(len(batch)))
+        batch = batch[:0]
+      } else if s.drop(batch, sendErr) {
+        sendErr = nil
+        batch = batch[:0]
+      }
+    }
+    if err != nil {
+      break
+    }
+  }
+  if sendErr == nil {
+    f.Close()
+    os.Remove(s.spillName)
+    s.spilled = false
+    s.backoff = s.conf.MinBackoff
+    return true
+  }
+
+  // keep the failed batch and the rest for the next attempt
+  tmp := s.spillName + ".tmp"
+  if out, err := os.Create(tmp); err == nil {
+    out.Write(bytes.Join(batch, nil))
+    io.Copy(out, r)
+    out.Close()
+    f.Close()
+    os.Rename(tmp, s.spillName)
+  } else {
+    f.Close()
+  }
+  s.nextReplay = time.Now().Add(s.backoff)
+  s.backoff *= 2
+  if s.backoff > s.conf.MaxBackoff {
+    s.backoff = s.conf.MaxBackoff
+  }
+  return false
+}
+
+// send delivers one batch to the collector.
+func (s *ShipperSink) send(batch [][]byte) error {
+  body := bytes.Join(batch, nil)
+  if s.url.Scheme == "tcp" {
+    if s.conn == nil {
+      conn, err := net.DialTimeout("tcp", s.url.Host, s.conf.Timeout)
+      if err != nil {
+        return err
+      }
+      s.conn = conn
+    }
+    s.conn.SetWriteDeadline(time.Now().Add(s.conf.Timeout))
+    if _, err := s.conn.Write(body); err != nil {
+      s.conn.Close()
+      s.conn = nil
+      return err
+    }
+    return nil
+  }
+  resp, err := s.client.Post(s.url.String(), "application/x-ndjson", bytes.NewReader(body))
+  if err != nil {
+    return err
+  }
+  io.Copy(io.Discard, resp.Body)
+  resp.Body.Close()
+  switch {
+  case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+    return nil
+  case resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests:
+    // retryable
+  case resp.StatusCode >= 400 && resp.StatusCode <= 499:
+    return rejectedError{resp.Status}
+  }
+  return fmt.Errorf("log: collector replied %s", resp.Status)
+}
@@ -0,0 +1,184 @@
+package log
+
+import (
+  "bufio"
+  "fmt"
+  "io"
+  "net"
+  "net/http"
+  "net/http/httptest"
+  "os"
+  "path/filepath"
+  "strings"
+  "sync"
+  "sync/atomic"
+  "testing"
+  "time"
+)
+
+func TestShipperHTTPSpillAndReplay(t *testing.T) {
+  var down int32 = 1
+  var mu sync.Mutex
+  var received []string
+  srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    if atomic.LoadInt32(&down) == 1 {
+      w.WriteHeader(http.StatusServiceUnavailable)
+      return
+    }
+    body, _ := io.ReadAll(r.Body)
+    mu.Lock()
+    received = append(received, strings.Split(strings.TrimSpace(string(body)), "\n")...)
+    mu.Unlock()
+  }))
+  defer srv.Close()
+
+  s, err := NewShipperSink(ShipperConfig{URL: srv.URL, Encoder: EncoderFunc(encodeMessage), BatchSize: 2,
+    FlushInterval: 5 * time.Millisecond, MaxRetries: 1, MinBackoff: time.Millisecond,
+    MaxBackoff: 2 * time.Millisecond, SpillDir: t.TempDir()})
+  if err != nil {
+    t.Fatal(err)
+  }
+  for i := 0; i < 4; i++ {
+    s.WriteRecord(&Record{Message: fmt.Sprint(i)})
+  }
+  deadline := time.Now().Add(time.Second)
+  for s.Stats().Spilled < 4 && time.Now().Before(deadline) {
+    time.Sleep(time.Millisecond)
+  }
+  atomic.StoreInt32(&down, 0)
+  for i := 4; i < 6; i++ {
+    s.WriteRecord(&Record{Message: fmt.Sprint(i)})
+  }
+  s.Close()
+
+  stats := s.Stats()
+  if stats.Sent != 6 || stats.Spilled < 4 || stats.Failed != 0 {
+    t.Errorf("stats %+v", stats)
+  }
+  if strings.Join(received, ",") != "0,1,2,3,4,5" {
+    t.Errorf("received %q", received)
+  }
+}
+
+func TestShipperTCP(t *testing.T) {
+  ln, err := net.Listen("tcp", "127.0.0.1:0")
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer ln.Close()
+  lines := make(chan string, 10)
+  go func() {
+    conn, err := ln.Accept()
+    if err != nil {
+      return
+    }
+    sc := bufio.NewScanner(conn)
+    for sc.Scan() {
+      lines <- sc.Text()
+    }
+  }()
+
+  s, err := NewShipperSink(ShipperConfig{URL: "tcp://" + ln.Addr().String(), FlushInterval: time.Millisecond})
+  if err != nil {
+    t.Fatal(err)
+  }
+  s.WriteRecord(&Record{Level: INFO, Message: "shipped"})
+  s.Close()
+  select {
+  case line := <-lines:
+    if line != `{"level":"INFO","msg":"shipped"}` {
+      t.Errorf("got %q", line)
+    }
+  case <-time.After(time.Second):
+    t.Fatal("timed out")
+  }
+  if stats := s.Stats(); stats.Sent != 1 {
+    t.Errorf("stats %+v", stats)
+  }
+}
+
+func TestShipperCloseTimeout(t *testing.T) {
+  hang := make(chan struct{})
+  srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    <-hang
+  }))
+  defer srv.Close()
+  defer close(hang)
+
+  s, err := NewShipperSink(ShipperConfig{URL: srv.URL, BatchSize: 1, MaxRetries: 5,
+    Timeout: 10 * time.Second, CloseTimeout: 100 * time.Millisecond})
+  if err != nil {
+    t.Fatal(err)
+  }
+  for i := 0; i < 3; i++ {
+    s.WriteRecord(&Record{Message: fmt.Sprint(i)})
+  }
+  start := time.Now()
+  if err := s.Close(); err == nil {
+    t.Error("Close didn't time out")
+  }
+  if d := time.Since(start); d > time.Second {
+    t.Errorf("Close took %v", d)
+  }
+  // records written once closing are counted as lost
+  failed := s.Stats().Failed
+  if err := s.WriteRecord(&Record{Message: "late"}); err == nil || s.Stats().Failed != failed+1 {
+    t.Errorf("late record accepted, stats %+v", s.Stats())
+  }
+}
+
+func TestShipperRejected(t *testing.T) {
+  var requests int32
+  srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    atomic.AddInt32(&requests, 1)
+    if body, _ := io.ReadAll(r.Body); strings.Contains(string(body), "bad") {
+      w.WriteHeader(http.StatusBadRequest)
+    }
+  }))
+  defer srv.Close()
+
+  dir := t.TempDir()
+  s, err := NewShipperSink(ShipperConfig{URL: srv.URL, Encoder: EncoderFunc(encodeMessage), BatchSize: 1,
+    FlushInterval: time.Millisecond, MinBackoff: time.Millisecond, SpillDir: dir})
+  if err != nil {
+    t.Fatal(err)
+  }
+  for _, m := range []string{"0", "bad", "2"} {
+    s.WriteRecord(&Record{Message: m})
+  }
+  s.Close()
+
+  if stats := s.Stats(); stats.Sent != 2 || stats.Failed != 1 || stats.Spilled != 0 {
+    t.Errorf("stats %+v", stats)
+  }
+  if n := atomic.LoadInt32(&requests); n != 3 {
+    t.Errorf("got %d requests, a rejected batch is retried", n)
+  }
+}
+
+func TestShipperMaxSpillSize(t *testing.T) {
+  srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    w.WriteHeader(http.StatusServiceUnavailable)
+  }))
+  defer srv.Close()
+
+  dir := t.TempDir()
+  s, err := NewShipperSink(ShipperConfig{URL: srv.URL, Encoder: EncoderFunc(encodeMessage), BatchSize: 1,
+    FlushInterval: time.Millisecond, MaxRetries: -1, MinBackoff: time.Hour, SpillDir: dir,
+    MaxSpillSize: 10, CloseTimeout: 100 * time.Millisecond})
+  if err != nil {
+    t.Fatal(err)
+  }
+  for i := 0; i < 10; i++ {
+    s.WriteRecord(&Record{Message: fmt.Sprint(i)})
+  }
+  s.Close()
+
+  stats := s.Stats()
+  if stats.Spilled == 0 || stats.Spilled+stats.Failed != 10 || stats.Failed == 0 {
+    t.Errorf("stats %+v", stats)
+  }
+  if info, err := os.Stat(filepath.Join(dir, "spill.ndjson")); err != nil || info.Size() > 10 {
+    t.Errorf("spill file %v, %v", info, err)
+  }
+}
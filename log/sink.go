@@ -0,0 +1,102 @@
+package log
+
+import (
+  "errors"
+  "io"
+  "os"
+  "sync"
+)
+
+// Sink is an output of a Logger. WriteRecord may be called concurrently.
+type Sink interface {
+  WriteRecord(r *Record) error
+  Close() error
+}
+
+// sinkEntry is a sink attached to a Logger with its minimum level.
+type sinkEntry struct {
+  sink  Sink
+  level LogLevel
+}
+
+// WriterSink encodes records and writes them to an io.Writer.
+type WriterSink struct {
+  mu  sync.Mutex
+  w   io.Writer
+  enc Encoder
+}
+
+// NewWriterSink returns a sink writing records encoded by enc to w. A nil
+// enc selects TextEncoder.
+func NewWriterSink(w io.Writer, enc Encoder) *WriterSink {
+  if enc == nil {
+    enc = TextEncoder
+  }
+  return &WriterSink{w: w, enc: enc}
+}
+
+// NewFileSink returns a sink writing to a log file configured by the same
+// decorators as a Logger, e.g.
+//
+//	NewFileSink(LogFilePath("/var/log/app", "errors.log"), EveryHour, LogEncoder(JSONEncoder))
+func NewFileSink(decorators ...func(Logger) Logger) (*WriterSink, error) {
+  conf := Logger{}
+  for _, decorator := range decorators {
+    conf = decorator(conf)
+  }
+  if conf.logPath == "" {
+    return nil, errors.New("log: file sink requires a log path")
+  }
+  segment := newLogSegment(conf)
+  if segment == nil {
+    return nil, errors.New("log: can't open log file in " + conf.logPath)
+  }
+  return NewWriterSink(segment, conf.encoder), nil
+}
+
+// WriteRecord encodes r and writes it in a single Write call.
+func (s *WriterSink) WriteRecord(r *Record) error {
+  buf := getBuffer()
+  s.enc.Encode(buf, r)
+  s.mu.Lock()
+  _, err := s.w.Write(buf.Bytes())
+  s.mu.Unlock()
+  putBuffer(buf)
+  return err
+}
+
+// Close closes the underlying writer if it is an io.Closer, except for the
+// standard output and error.
+func (s *WriterSink) Close() error {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  switch w := s.w.(type) {
+  case *logSegment:
+    w.Close()
+  case *os.File:
+    if w == os.Stdout || w == os.Stderr {
+      return nil
+    }
+    return w.Close()
+  case io.Closer:
+    return w.Close()
+  }
+  return nil
+}
+
+// noCloseWriter hides the Close method of a writer owned by someone else.
+type noCloseWriter struct {
+  io.Writer
+}
+
+// LogSink returns a function to attach an additional sink receiving the
+// records at or above minLevel. The sink is closed when the logger stops.
+// Records below the logger level never reach any sink.
+func LogSink(s Sink, minLevel LogLevel) func(Logger) Logger {
+  return func(l Logger) Logger {
+    sinks := make([]sinkEntry, len(l.sinks), len(l.sinks)+1)
+    copy(sinks, l.sinks)
+    l.sinks = append(sinks, sinkEntry{sink: s, level: minLevel})
+    return l
+  }
+}
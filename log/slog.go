@@ -0,0 +1,202 @@
+package log
+
+import (
+  "context"
+  "log/slog"
+  "runtime"
+)
+
+// SlogHandler is a slog.Handler writing through a Logger. Groups become
+// nested fields, see Group.
+type SlogHandler struct {
+  logger *Logger
+  fields []Field
+  groups []slogGroup // open groups, outermost first
+}
+
+type slogGroup struct {
+  name   string
+  fields []Field
+}
+
+// NewSlogHandler returns a slog.Handler logging through l. Records at FATAL
+// level don't exit the process.
+func NewSlogHandler(l *Logger) *SlogHandler {
+  return &SlogHandler{logger: l}
+}
+
+// SlogHandler returns a slog.Handler logging through l with its fields.
+func (l *LogAdaptor) SlogHandler() *SlogHandler {
+  return &SlogHandler{logger: l.current(), fields: l.fields}
+}
+
+// Enabled reports whether the logger level lets level through.
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+  return slogLevel(level) >= h.logger.namedLevel()
+}
+
+// Handle logs r.
+func (h *SlogHandler) Handle(ctx context.Context, sr slog.Record) error {
+  l := h.logger
+  if !l.running() {
+    return nil
+  }
+  r := Record{
+    Time:    sr.Time,
+    Level:   slogLevel(sr.Level),
+    Message: sr.Message,
+  }
+  if l.callerFlags() > 0 && sr.PC != 0 {
+    frame, _ := runtime.CallersFrames([]uintptr{sr.PC}).Next()
+    l.setCaller(&r, frame.Function, frame.File, frame.Line)
+  }
+
+  var fields []Field
+  sr.Attrs(func(a slog.Attr) bool {
+    fields = appendAttr(fields, a)
+    return true
+  })
+  for i := len(h.groups) - 1; i >= 0; i-- {
+    fields = mergeFields(h.groups[i].fields, fields)
+    if len(fields) > 0 {
+      fields = []Field{Group(h.groups[i].name, fields...)}
+    }
+  }
+  r.Fields = mergeFields(mergeFields(l.contextFields(ctx), h.fields), fields)
+  l.dispatch(&r)
+  return nil
+}
+
+// WithAttrs returns a handler adding attrs to the records, in the open
+// groups.
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+  var fields []Field
+  for _, a := range attrs {
+    fields = appendAttr(fields, a)
+  }
+  if len(fields) == 0 {
+    return h
+  }
+  child := *h
+  if n := len(h.groups); n > 0 {
+    child.groups = make([]slogGroup, n)
+    copy(child.groups, h.groups)
+    child.groups[n-1].fields = mergeFields(h.groups[n-1].fields, fields)
+  } else {
+    child.fields = mergeFields(h.fields, fields)
+  }
+  return &child
+}
+
+// WithGroup returns a handler nesting the attributes added afterwards under
+// name.
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+  if name == "" {
+    return h
+  }
+  child := *h
+  child.groups = make([]slogGroup, len(h.groups), len(h.groups)+1)
+  copy(child.groups, h.groups)
+  child.groups = append(child.groups, slogGroup{name: name})
+  return &child
+}
+
+// appendAttr appends a as a field, following the slog.Handler rules: empty
+// attributes and groups are dropped, groups without a key are inlined.
+func appendAttr(fields []Field, a slog.Attr) []Field {
+  a.Value = a.Value.Resolve()
+  if a.Equal(slog.Attr{}) {
+    return fields
+  }
+  if a.Value.Kind() != slog.KindGroup {
+    return append(fields, Field{Key: a.Key, Value: a.Value.Any()})
+  }
+  var group []Field
+  for _, ga := range a.Value.Group() {
+    group = appendAttr(group, ga)
+  }
+  if len(group) == 0 {
+    return fields
+  }
+  if a.Key == "" {
+    return append(fields, group...)
+  }
+  return append(fields, Group(a.Key, group...))
+}
+
+// slogLevel maps a slog level to the closest level at or below it.
+func slogLevel(level slog.Level) LogLevel {
+  switch {
+  case level < slog.LevelDebug:
+    return TRACE
+  case level < slog.LevelInfo:
+    return DEBUG
+  case level < slog.LevelWarn:
+    return INFO
+  case level < slog.LevelError:
+    return WARN
+  case level < slog.LevelError+4:
+    return ERROR
+  default:
+    return FATAL
+  }
+}
+
+var levelSlog = map[LogLevel]slog.Level{
+  TRACE: slog.LevelDebug - 4,
+  DEBUG: slog.LevelDebug,
+  INFO:  slog.LevelInfo,
+  WARN:  slog.LevelWarn,
+  ERROR: slog.LevelError,
+  FATAL: slog.LevelError + 4,
+}
+
+// SlogSink forwards records to a slog.Handler.
+type SlogSink struct {
+  handler slog.Handler
+}
+
+// NewSlogSink returns a sink forwarding records to h.
+func NewSlogSink(h slog.Handler) *SlogSink {
+  return &SlogSink{handler: h}
+}
+
+// WriteRecord hands r to the handler if it is enabled for its level.
+func (s *SlogSink) WriteRecord(r *Record) error {
+  ctx := context.Background()
+  level := levelSlog[r.Level]
+  if !s.handler.Enabled(ctx, level) {
+    return nil
+  }
+  sr := slog.NewRecord(r.Time, level, r.Message, 0)
+  for _, f := range r.Fields {
+    sr.AddAttrs(fieldAttr(f))
+  }
+  return s.handler.Handle(ctx, sr)
+}
+
+// Close does nothing, the handler is owned by the caller.
+func (s *SlogSink) Close() error {
+  return nil
+}
+
+func fieldAttr(f Field) slog.Attr {
+  group, ok := f.Value.([]Field)
+  if !ok {
+    return slog.Any(f.Key, f.Value)
+  }
+  attrs := make([]slog.Attr, len(group))
+  for i, gf := range group {
+    attrs[i] = fieldAttr(gf)
+  }
+  return slog.Attr{Key: f.Key, Value: slog.GroupValue(attrs...)}
+}
+
+// SlogOutput returns a function to forward the records to h, instead of
+// stderr when no log file is set.
+func SlogOutput(h slog.Handler) func(Logger) Logger {
+  return func(l Logger) Logger {
+    l.noStderr = true
+    return LogSink(NewSlogSink(h), TRACE)(l)
+  }
+}
@@ -0,0 +1,78 @@
+package log
+
+import (
+  "bytes"
+  "encoding/json"
+  "log/slog"
+  "os"
+  "path"
+  "strings"
+  "testing"
+  "testing/slogtest"
+)
+
+func parseJSONLines(t *testing.T, data []byte) []map[string]any {
+  var ms []map[string]any
+  for _, line := range bytes.Split(bytes.TrimSpace(data), []byte("\n")) {
+    if len(line) == 0 {
+      continue
+    }
+    var m map[string]any
+    if err := json.Unmarshal(line, &m); err != nil {
+      t.Fatalf("%s: %v", line, err)
+    }
+    ms = append(ms, m)
+  }
+  return ms
+}
+
+func TestSlogHandler(t *testing.T) {
+  dir := t.TempDir()
+  inst := NewLogInstance(LogFilePath(dir, "slog.log"), LogEncoder(JSONEncoder))
+  defer inst.Stop()
+
+  err := slogtest.TestHandler(NewSlogHandler(inst), func() []map[string]any {
+    data, err := os.ReadFile(path.Join(dir, "slog.log"))
+    if err != nil {
+      t.Fatal(err)
+    }
+    ms := parseJSONLines(t, data)
+    for _, m := range ms {
+      if ts, ok := m["ts"]; ok {
+        m[slog.TimeKey] = ts
+        delete(m, "ts")
+      }
+    }
+    return ms
+  })
+  if err != nil {
+    t.Error(err)
+  }
+}
+
+func TestSlogBridge(t *testing.T) {
+  var buf bytes.Buffer
+  inst := NewLogInstance(SlogOutput(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug - 4})))
+  defer inst.Stop()
+
+  err := slogtest.TestHandler(NewSlogHandler(inst), func() []map[string]any {
+    return parseJSONLines(t, buf.Bytes())
+  })
+  if err != nil {
+    t.Error(err)
+  }
+
+  buf.Reset()
+  l := NewAdaptorFromInstance(inst, 3)
+  l.With("k", "v").Tracew("traced", Group("g", Int("n", 1)))
+  out := buf.String()
+  if !strings.Contains(out, `"level":"DEBUG-4","msg":"traced","k":"v","g":{"n":1}`) {
+    t.Errorf("unexpected output %s", out)
+  }
+
+  buf.Reset()
+  TextEncoder.Encode(&buf, &Record{Level: INFO, Message: "m", Fields: []Field{Group("a", Group("b", Int("c", 1)))}})
+  if buf.String() != "INF: m a.b.c=1\n" {
+    t.Errorf("unexpected text %q", buf.String())
+  }
+}
@@ -0,0 +1,301 @@
+package log
+
+import (
+  "bytes"
+  "errors"
+  "net"
+  "os"
+  "path"
+  "strconv"
+  "strings"
+  "sync"
+  "time"
+)
+
+// SyslogFormat selects the syslog message format.
+type SyslogFormat int
+
+const (
+  // RFC5424 is the IETF syslog format.
+  RFC5424 SyslogFormat = iota
+  // RFC3164 is the BSD syslog format.
+  RFC3164
+)
+
+// Facility is a syslog facility.
+type Facility int
+
+const (
+  FacilityUser   Facility = 1
+  FacilityDaemon Facility = 3
+  FacilityAuth   Facility = 4
+  FacilityLocal0 Facility = 16
+  FacilityLocal1 Facility = 17
+  FacilityLocal2 Facility = 18
+  FacilityLocal3 Facility = 19
+  FacilityLocal4 Facility = 20
+  FacilityLocal5 Facility = 21
+  FacilityLocal6 Facility = 22
+  FacilityLocal7 Facility = 23
+)
+
+var syslogSeverity = map[LogLevel]int{
+  TRACE: 7, // debug
+  DEBUG: 7, // debug
+  INFO:  6, // informational
+  WARN:  4, // warning
+  ERROR: 3, // error
+  FATAL: 2, // critical
+}
+
+var (
+  // errSyslogBackoff is returned while waiting to reconnect.
+  errSyslogBackoff = errors.New("log: syslog connection down, waiting to reconnect")
+  // errSyslogClosed is returned once the sink is closed.
+  errSyslogClosed = errors.New("log: syslog sink closed")
+)
+
+// SyslogConfig configures a SyslogSink.
+type SyslogConfig struct {
+  // Network is "udp", "tcp", "unix" or "unixgram". When Network and Addr
+  // are empty the local syslog daemon is used through /dev/log.
+  Network string
+  Addr    string
+  Format  SyslogFormat
+  // Facility defaults to FacilityUser.
+  Facility Facility
+  // AppName defaults to the program name, ProcID to the process id and
+  // Hostname to os.Hostname().
+  AppName  string
+  ProcID   string
+  Hostname string
+  // Encoder renders the message part, by default the message followed by
+  // the fields in logfmt.
+  Encoder Encoder
+  // DialTimeout defaults to 5s. Reconnection attempts are spaced from
+  // MinBackoff (default 100ms) doubling up to MaxBackoff (default 30s).
+  DialTimeout time.Duration
+  MinBackoff  time.Duration
+  MaxBackoff  time.Duration
+}
+
+// SyslogSink delivers records to a syslog daemon or collector.
+type SyslogSink struct {
+  conf    SyslogConfig
+  mu      sync.Mutex
+  conn    net.Conn
+  framed  bool // octet counting framing on stream connections
+  backoff time.Duration
+  retryAt time.Time
+  dialing bool
+  closed  bool
+}
+
+// NewSyslogSink connects to the syslog server described by conf.
+func NewSyslogSink(conf SyslogConfig) (*SyslogSink, error) {
+  if conf.Facility == 0 {
+    conf.Facility = FacilityUser
+  }
+  if conf.AppName == "" {
+    conf.AppName = path.Base(os.Args[0])
+  }
+  if conf.ProcID == "" {
+    conf.ProcID = strconv.Itoa(os.Getpid())
+  }
+  if conf.Hostname == "" {
+    conf.Hostname, _ = os.Hostname()
+  }
+  if conf.Encoder == nil {
+    conf.Encoder = EncoderFunc(encodeMessage)
+  }
+  if conf.DialTimeout <= 0 {
+    conf.DialTimeout = 5 * time.Second
+  }
+  if conf.MinBackoff <= 0 {
+    conf.MinBackoff = 100 * time.Millisecond
+  }
+  if conf.MaxBackoff < conf.MinBackoff {
+    conf.MaxBackoff = 30 * time.Second
+  }
+  s := &SyslogSink{conf: conf, backoff: conf.MinBackoff}
+  conn, framed, err := s.connect()
+  if err != nil {
+    return nil, err
+  }
+  s.conn, s.framed = conn, framed
+  return s, nil
+}
+
+// connect dials the server and reports whether the connection needs framing.
+func (s *SyslogSink) connect() (net.Conn, bool, error) {
+  network, addr := s.conf.Network, s.conf.Addr
+  if network == "" && addr == "" {
+    var err error
+    for _, addr := range []string{"/dev/log", "/var/run/syslog", "/var/run/log"} {
+      var conn net.Conn
+      if conn, err = net.DialTimeout("unixgram", addr, s.conf.DialTimeout); err == nil {
+        return conn, false, nil
+      }
+    }
+    return nil, false, err
+  }
+  conn, err := net.DialTimeout(network, addr, s.conf.DialTimeout)
+  if err != nil {
+    return nil, false, err
+  }
+  switch network {
+  case "tcp", "tcp4", "tcp6", "unix":
+    return conn, true, nil
+  }
+  return conn, false, nil
+}
+
+// dial connects if the connection was lost. It dials without holding the
+// lock, so the records logged meanwhile fail fast instead of waiting for
+// DialTimeout.
+func (s *SyslogSink) dial() error {
+  s.mu.Lock()
+  switch {
+  case s.closed:
+    s.mu.Unlock()
+    return errSyslogClosed
+  case s.conn != nil:
+    s.mu.Unlock()
+    return nil
+  case s.dialing || time.Now().Before(s.retryAt):
+    s.mu.Unlock()
+    return errSyslogBackoff
+  }
+  s.dialing = true
+  s.mu.Unlock()
+
+  conn, framed, err := s.connect()
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  s.dialing = false
+  if s.closed {
+    if conn != nil {
+      conn.Close()
+    }
+    return errSyslogClosed
+  }
+  if err != nil {
+    s.retryAt = time.Now().Add(s.backoff)
+    s.backoff *= 2
+    if s.backoff > s.conf.MaxBackoff {
+      s.backoff = s.conf.MaxBackoff
+    }
+    return err
+  }
+  s.conn, s.framed = conn, framed
+  s.backoff = s.conf.MinBackoff
+  return nil
+}
+
+// WriteRecord sends r as a single syslog message, reconnecting if the
+// connection was lost.
+func (s *SyslogSink) WriteRecord(r *Record) error {
+  buf := getBuffer()
+  defer putBuffer(buf)
+  var err error
+  for attempt := 0; attempt < 2; attempt++ {
+    if err = s.dial(); err != nil {
+      return err
+    }
+    s.mu.Lock()
+    if s.conn == nil {
+      // lost or closed meanwhile
+      s.mu.Unlock()
+      continue
+    }
+    buf.Reset()
+    s.format(buf, r)
+    if _, err = s.conn.Write(buf.Bytes()); err == nil {
+      s.mu.Unlock()
+      return nil
+    }
+    s.conn.Close()
+    s.conn = nil
+    s.mu.Unlock()
+  }
+  return err
+}
+
+// format writes the syslog message of r, framed for stream connections.
+func (s *SyslogSink) format(buf *bytes.Buffer, r *Record) {
+  t := r.Time
+  if t.IsZero() {
+    t = time.Now()
+  }
+  body := getBuffer()
+  defer putBuffer(body)
+  s.conf.Encoder.Encode(body, r)
+  msg := bytes.TrimRight(body.Bytes(), "\n")
+
+  var head strings.Builder
+  head.WriteByte('<')
+  head.WriteString(strconv.Itoa(int(s.conf.Facility)*8 + syslogSeverity[r.Level]))
+  head.WriteByte('>')
+  if s.conf.Format == RFC3164 {
+    head.WriteString(t.Format(time.Stamp))
+    head.WriteByte(' ')
+    // local daemons add the hostname themselves
+    if !strings.HasPrefix(s.conf.Network, "unix") && s.conf.Network != "" {
+      head.WriteString(syslogToken(s.conf.Hostname))
+      head.WriteByte(' ')
+    }
+    head.WriteString(s.conf.AppName)
+    head.WriteByte('[')
+    head.WriteString(s.conf.ProcID)
+    head.WriteString("]: ")
+  } else {
+    head.WriteString("1 ")
+    head.WriteString(t.Format("2006-01-02T15:04:05.000000Z07:00"))
+    for _, token := range []string{s.conf.Hostname, s.conf.AppName, s.conf.ProcID, "-", "-"} {
+      head.WriteByte(' ')
+      head.WriteString(syslogToken(token))
+    }
+    head.WriteByte(' ')
+  }
+
+  if s.framed {
+    buf.WriteString(strconv.Itoa(head.Len() + len(msg)))
+    buf.WriteByte(' ')
+  }
+  buf.WriteString(head.String())
+  buf.Write(msg)
+}
+
+// Close closes the connection. Later records are rejected.
+func (s *SyslogSink) Close() error {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  s.closed = true
+  if s.conn == nil {
+    return nil
+  }
+  err := s.conn.Close()
+  s.conn = nil
+  return err
+}
+
+// syslogToken makes s a valid header field: printable ASCII without spaces,
+// or "-" when empty.
+func syslogToken(s string) string {
+  if s == "" {
+    return "-"
+  }
+  return strings.Map(func(r rune) rune {
+    if r <= ' ' || r > '~' {
+      return '_'
+    }
+    return r
+  }, s)
+}
+
+// encodeMessage writes the message followed by the fields.
+func encodeMessage(buf *bytes.Buffer, r *Record) {
+  buf.WriteString(r.Message)
+  appendFields(buf, r.Fields)
+  buf.WriteByte('\n')
+}
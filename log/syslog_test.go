@@ -0,0 +1,151 @@
+package log
+
+import (
+  "bufio"
+  "net"
+  "os"
+  "path/filepath"
+  "regexp"
+  "sort"
+  "strconv"
+  "strings"
+  "testing"
+  "time"
+)
+
+func TestSyslogUDP(t *testing.T) {
+  pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer pc.Close()
+  s, err := NewSyslogSink(SyslogConfig{Network: "udp", Addr: pc.LocalAddr().String(),
+    Facility: FacilityLocal0, AppName: "app", ProcID: "42", Hostname: "host"})
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer s.Close()
+  s.WriteRecord(&Record{Time: time.Now(), Level: WARN, Message: "disk low", Fields: []Field{Int("free", 3)}})
+
+  buf := make([]byte, 1024)
+  pc.SetReadDeadline(time.Now().Add(time.Second))
+  n, _, err := pc.ReadFrom(buf)
+  if err != nil {
+    t.Fatal(err)
+  }
+  // local0 * 8 + warning
+  re := regexp.MustCompile(`^<132>1 \S+ host app 42 - - disk low free=3$`)
+  if !re.Match(buf[:n]) {
+    t.Errorf("got %q", buf[:n])
+  }
+}
+
+func TestSyslogTCPReconnect(t *testing.T) {
+  ln, err := net.Listen("tcp", "127.0.0.1:0")
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer ln.Close()
+  frames := make(chan string, 2)
+  go func() {
+    for {
+      conn, err := ln.Accept()
+      if err != nil {
+        return
+      }
+      go func() {
+        r := bufio.NewReader(conn)
+        for {
+          size, err := r.ReadString(' ')
+          if err != nil {
+            return
+          }
+          n, _ := strconv.Atoi(strings.TrimSpace(size))
+          msg := make([]byte, n)
+          if _, err := r.Read(msg); err != nil {
+            return
+          }
+          frames <- string(msg)
+        }
+      }()
+    }
+  }()
+
+  s, err := NewSyslogSink(SyslogConfig{Network: "tcp", Addr: ln.Addr().String(), Format: RFC3164,
+    AppName: "app", ProcID: "42", Hostname: "host"})
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer s.Close()
+  s.WriteRecord(&Record{Time: time.Now(), Level: ERROR, Message: "first"})
+  // drop the connection, the next write dials again
+  s.conn.Close()
+  if err := s.WriteRecord(&Record{Time: time.Now(), Level: INFO, Message: "second"}); err != nil {
+    t.Fatal(err)
+  }
+  // the two connections are read concurrently
+  var got []string
+  for i := 0; i < 2; i++ {
+    select {
+    case msg := <-frames:
+      got = append(got, msg)
+    case <-time.After(time.Second):
+      t.Fatal("timed out")
+    }
+  }
+  sort.Strings(got)
+  for i, want := range []string{`^<11>\w{3} [ \d]\d \d\d:\d\d:\d\d host app\[42\]: first$`,
+    `^<14>\w{3} [ \d]\d \d\d:\d\d:\d\d host app\[42\]: second$`} {
+    if !regexp.MustCompile(want).MatchString(got[i]) {
+      t.Errorf("got %q, want %s", got[i], want)
+    }
+  }
+
+  // a record logged while another one dials fails fast
+  s.mu.Lock()
+  s.conn.Close()
+  s.conn = nil
+  s.dialing = true
+  s.mu.Unlock()
+  if err := s.WriteRecord(&Record{Time: time.Now(), Level: INFO, Message: "dialing"}); err != errSyslogBackoff {
+    t.Errorf("got %v while dialing", err)
+  }
+  s.mu.Lock()
+  s.dialing = false
+  s.mu.Unlock()
+  // a closed sink stays closed
+  s.Close()
+  if err := s.WriteRecord(&Record{Time: time.Now(), Level: INFO, Message: "closed"}); err != errSyslogClosed {
+    t.Errorf("got %v after Close", err)
+  }
+}
+
+func TestSyslogUnixgram(t *testing.T) {
+  dir, err := os.MkdirTemp("", "syslog")
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer os.RemoveAll(dir)
+  addr := filepath.Join(dir, "log.sock")
+  pc, err := net.ListenPacket("unixgram", addr)
+  if err != nil {
+    t.Skip(err)
+  }
+  defer pc.Close()
+  s, err := NewSyslogSink(SyslogConfig{Network: "unixgram", Addr: addr, Format: RFC3164, AppName: "app", ProcID: "1"})
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer s.Close()
+  s.WriteRecord(&Record{Level: DEBUG, Message: "local"})
+  buf := make([]byte, 1024)
+  pc.SetReadDeadline(time.Now().Add(time.Second))
+  n, _, err := pc.ReadFrom(buf)
+  if err != nil {
+    t.Fatal(err)
+  }
+  // no hostname for the local daemon
+  if !regexp.MustCompile(`^<15>\w{3} [ \d]\d \d\d:\d\d:\d\d app\[1\]: local$`).Match(buf[:n]) {
+    t.Errorf("got %q", buf[:n])
+  }
+}